@@ -2,13 +2,19 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
 
 	"plotng/internal"
 )
 
 func main() {
-	hosts := flag.String("hosts", "localhost", "hosts to query, separated by comma, default: localhost")
+	hosts := flag.String("hosts", "", "hosts to query, separated by comma, default: localhost unless -hosts-file or -discover is given")
+	hostsFile := flag.String("hosts-file", "", "JSON file with the hosts to query, reloaded when it changes")
+	discover := flag.Bool("discover", false, "query the plotters announcing themselves on the LAN")
 	alternateMouse := flag.Bool("alternate-mouse", false, "use alternate mouse setup (for PuTTy)")
+	format := flag.String("format", internal.FormatTable, "output format of commands: table, json or csv")
+	config := flag.String("config", internal.DefaultClientConfigPath(), "JSON file with the layout of the panels and columns, saved when they are changed")
 
 	flag.Parse()
 	if flag.Parsed() == false {
@@ -17,6 +23,16 @@ func main() {
 	}
 	client := &internal.Client{
 		AlternateMouse: *alternateMouse,
+		HostsFile:      *hostsFile,
+		Discover:       *discover,
+		ConfigFile:     *config,
+	}
+	if flag.NArg() > 0 {
+		if err := client.RunCommand(*hosts, flag.Args(), *format, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
 	client.ProcessLoop(*hosts)
 }
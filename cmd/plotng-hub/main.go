@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+
+	"plotng/internal"
+)
+
+func main() {
+	hosts := flag.String("hosts", "", "plotters to poll, separated by comma")
+	hostsFile := flag.String("hosts-file", "", "JSON file with the plotters to poll, reloaded when it changes")
+	stateFile := flag.String("state", "plotng-hub.state", "file keeping the archived plots of every plotter")
+	token := flag.String("token", "", "token required from clients, default: none")
+	archiveMaxCount := flag.Int("archive-max-count", 0, "archived plots kept per plotter, default: 0 - no limit")
+	archiveMaxDays := flag.Int("archive-max-days", 0, "days to keep archived plots, by their end time, default: 0 - no limit")
+	address := flag.String("address", "", "local address to bind to, default any")
+	port := flag.Int("port", 8485, "hub port number, default: 8485")
+
+	flag.Parse()
+	if flag.Parsed() == false || (len(*hosts) == 0 && len(*hostsFile) == 0) {
+		flag.Usage()
+		return
+	}
+	hub := &internal.Hub{
+		HostsFile:       *hostsFile,
+		StatePath:       *stateFile,
+		AuthToken:       *token,
+		ArchiveMaxCount: *archiveMaxCount,
+		ArchiveMaxDays:  *archiveMaxDays,
+	}
+	hub.ProcessLoop(*hosts, *address, *port)
+}
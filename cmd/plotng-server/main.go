@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"time"
 
 	"plotng/internal"
 )
@@ -10,12 +11,26 @@ func main() {
 	configFile := flag.String("config", "", "configuration file")
 	address := flag.String("address", "", "local address to bind to, default any")
 	port := flag.Int("port", 8484, "host server port number, default: 8484")
+	announce := flag.Bool("announce", false, "announce the server on the LAN for plotng-client -discover")
+	simulate := flag.Bool("simulate", false, "run a fake plotter on an accelerated clock to try the configuration")
+	speed := flag.Float64("simulate-speed", 60, "how much faster than real time the simulation runs")
+	hours := flag.Int("simulate-hours", 0, "simulated hours after which the simulation stops, default: never")
+	space := flag.Int("simulate-space", 0, "free space of each destination directory in GB at the start of the simulation, default: its real free space")
+	replay := flag.String("simulate-replay", "", "archive exported as JSON whose phase durations are replayed, default: a model of k32 plots")
 
 	flag.Parse()
 	if flag.Parsed() == false || (len(*configFile) == 0) {
 		flag.Usage()
 		return
 	}
-	server := &internal.Server{}
+	server := &internal.Server{Announce: *announce}
+	if *simulate {
+		server.Simulation = &internal.Simulation{
+			Speed:     *speed,
+			Duration:  time.Duration(*hours) * time.Hour,
+			DiskSpace: uint64(*space) * internal.GB,
+			Replay:    *replay,
+		}
+	}
 	server.ProcessLoop(*configFile, *address, *port)
 }
@@ -10,9 +10,11 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -27,8 +29,11 @@ const (
 	PlotError
 	PlotFinished
 	PlotKilled
+	PlotStalled
 )
 
+// ActivePlot is a plot and its plotter.  While it runs, the fields changed by the plotter are written
+// under lock, so other goroutines must read them under lock or from a snapshot.
 type ActivePlot struct {
 	PlotId          int64
 	StartTime       time.Time
@@ -59,8 +64,37 @@ type ActivePlot struct {
 	UseTargetForTmp2 bool
 	BucketSize       int
 	SavePlotLogDir   string
+	LastLogTime      time.Time
+	CommandLine      string
+	TableTimings     []TableTiming
+	FinalPath        string
+	FinalSize        uint64
+	CPUSet           string // cores the plotter is pinned to
+	NumaNode         string // NUMA node the plotter is pinned to, if any
 	process          *os.Process
+	killed           bool
 	useMadmaxPlotter bool
+	logFile          *os.File
+	logStore         *LogStore
+	fullLog          *os.File
+	logSize          int64
+	logTruncated     bool // the full log reached the MaxSize of the log store
+	events           *eventHub
+	version          uint64 // sequence number of the last event of the plot
+	tailPending      bool   // a PlotEventTail is due, see publishTail
+	hooks            HookConfig
+	hookLock         sync.Mutex
+	hookQueue        []queuedHook
+	currentTables    string // tables being computed or compressed, as named in TableTimings
+	clock            *clock // nil for the real time
+	cgroup           string // cgroup v2 directory of the plotter
+	simulated        *simulatedPlot
+}
+
+// TableTiming is the time the plotter reported for computing or compressing tables.
+type TableTiming struct {
+	Name    string
+	Seconds float64
 }
 
 // getPhaseTime returns the end time of a phase. phase 0 is the start time
@@ -114,22 +148,31 @@ func (ap *ActivePlot) getProgress() int {
 	}
 }
 
+// stateString returns the display name of a plot state.
+func stateString(state int) string {
+	switch state {
+	case PlotRunning:
+		return "Running"
+	case PlotError:
+		return "Errored"
+	case PlotFinished:
+		return "Finished"
+	case PlotKilled:
+		return "Killed"
+	case PlotStalled:
+		return "Stalled"
+	}
+	return "Unknown"
+}
+
 func (ap *ActivePlot) Duration(currentTime time.Time) string {
 	return DurationString(currentTime.Sub(ap.StartTime))
 }
 
 func (ap *ActivePlot) String(showLog bool) string {
 	ap.lock.RLock()
-	state := "Unknown"
-	switch ap.State {
-	case PlotRunning:
-		state = "Running"
-	case PlotError:
-		state = "Errored"
-	case PlotFinished:
-		state = "Finished"
-	}
-	s := fmt.Sprintf("Plot [%s] - %s, Phase: %s %s, Start Time: %s, Duration: %s, Tmp Dir: %s, Dst Dir: %s\n", ap.Id, state, ap.Phase, ap.Progress, ap.StartTime.Format("2006-01-02 15:04:05"), ap.Duration(time.Now()), ap.PlotDir, ap.TargetDir)
+	state := stateString(ap.State)
+	s := fmt.Sprintf("Plot [%s] - %s, Phase: %s %s, Start Time: %s, Duration: %s, Tmp Dir: %s, Dst Dir: %s\n", ap.Id, state, ap.Phase, ap.Progress, ap.StartTime.Format("2006-01-02 15:04:05"), ap.Duration(ap.clock.Now()), ap.PlotDir, ap.TargetDir)
 	if showLog {
 		for _, l := range ap.Tail {
 			s += fmt.Sprintf("\t%s", l)
@@ -139,53 +182,218 @@ func (ap *ActivePlot) String(showLog bool) string {
 	return s
 }
 
+// snapshot returns a copy of the plot, which does not change while the plot keeps running.
+func (ap *ActivePlot) snapshot() *ActivePlot {
+	ap.lock.RLock()
+	defer ap.lock.RUnlock()
+	return &ActivePlot{
+		PlotId:           ap.PlotId,
+		StartTime:        ap.StartTime,
+		EndTime:          ap.EndTime,
+		TargetDir:        ap.TargetDir,
+		PlotDir:          ap.PlotDir,
+		Fingerprint:      ap.Fingerprint,
+		FarmerPublicKey:  ap.FarmerPublicKey,
+		PoolPublicKey:    ap.PoolPublicKey,
+		ContractAddress:  ap.ContractAddress,
+		Threads:          ap.Threads,
+		PlotSize:         ap.PlotSize,
+		Buffers:          ap.Buffers,
+		DisableBitField:  ap.DisableBitField,
+		Tmp2Dir:          ap.Tmp2Dir,
+		Phase:            ap.Phase,
+		Tail:             append([]string(nil), ap.Tail...),
+		State:            ap.State,
+		Id:               ap.Id,
+		Progress:         ap.Progress,
+		Phase1Time:       ap.Phase1Time,
+		Phase2Time:       ap.Phase2Time,
+		Phase3Time:       ap.Phase3Time,
+		Phase4Time:       ap.Phase4Time,
+		Pid:              ap.Pid,
+		UseTargetForTmp2: ap.UseTargetForTmp2,
+		BucketSize:       ap.BucketSize,
+		SavePlotLogDir:   ap.SavePlotLogDir,
+		LastLogTime:      ap.LastLogTime,
+		CommandLine:      ap.CommandLine,
+		TableTimings:     append([]TableTiming(nil), ap.TableTimings...),
+		FinalPath:        ap.FinalPath,
+		FinalSize:        ap.FinalSize,
+		CPUSet:           ap.CPUSet,
+		NumaNode:         ap.NumaNode,
+		version:          ap.getVersion(),
+		clock:            ap.clock,
+	}
+}
+
+// running reports whether the plotter has not ended yet.
+func (ap *ActivePlot) running() bool {
+	ap.lock.RLock()
+	defer ap.lock.RUnlock()
+	return ap.State == PlotRunning || ap.State == PlotStalled
+}
+
 func (ap *ActivePlot) RunPlot(config *Config) {
-	ap.StartTime = time.Now()
+	cmdStr, args := ap.createCmd(config)
+	ap.lock.Lock()
+	ap.StartTime = ap.clock.Now()
+	ap.CommandLine = commandLine(cmdStr, args)
+	ap.hooks = config.Hooks
+	ap.lock.Unlock()
+	ap.openFullLog()
+	state := PlotError
 	defer func() {
-		ap.EndTime = time.Now()
+		ap.closeLog()
+		ap.removeCgroup()
+		ap.finish(state)
 	}()
-	cmdStr, args := ap.createCmd(config)
 
 	cmd := exec.Command(cmdStr, args...)
-	ap.State = PlotRunning
+	ap.setState(PlotRunning)
+	var logs sync.WaitGroup
 	if stderr, err := cmd.StderrPipe(); err != nil {
-		ap.State = PlotError
 		log.Printf("Failed to start Plotting: %s", err)
 		return
 	} else {
-		go ap.processLogs(stderr)
+		logs.Add(1)
+		go func() {
+			ap.processLogs(stderr)
+			logs.Done()
+		}()
 	}
 	if stdout, err := cmd.StdoutPipe(); err != nil {
-		ap.State = PlotError
 		log.Printf("Failed to start Plotting: %s", err)
 		return
 	} else {
-		go ap.processLogs(stdout)
+		logs.Add(1)
+		go func() {
+			ap.processLogs(stdout)
+			logs.Done()
+		}()
 	}
 	//log.Println(cmd.String())
 
-	if err := cmd.Start(); err != nil {
+	if err := ap.startPlotter(cmd, &config.Resources); err != nil {
 		log.Printf("Failed to start chia command: %s", err)
-		ap.State = PlotError
 		return
 	} else {
+		ap.lock.Lock()
 		ap.process = cmd.Process
 		ap.Pid = cmd.Process.Pid
+		killed := ap.killed
+		ap.lock.Unlock()
+		if killed {
+			cmd.Process.Kill()
+		}
+		ap.queueHook(HookOnStart)
+		// all output must be read before calling Wait, which closes the pipes
+		logs.Wait()
 		if err := cmd.Wait(); err != nil {
-			if ap.State != PlotKilled {
-				ap.State = PlotError
-				log.Printf("Plotting Exit with Error: %s", err)
-			} else {
+			ap.lock.RLock()
+			killed := ap.killed
+			ap.lock.RUnlock()
+			if killed {
+				state = PlotKilled
 				log.Printf("Plot [%s] Killed", ap.Id)
+			} else {
+				log.Printf("Plotting Exit with Error: %s", err)
 			}
 			ap.cleanup()
 			return
 		}
 	}
-	ap.State = PlotFinished
+	if len(ap.FinalPath) > 0 {
+		if fi, err := os.Stat(ap.FinalPath); err == nil {
+			ap.lock.Lock()
+			ap.FinalSize = uint64(fi.Size())
+			ap.lock.Unlock()
+		}
+	}
+	state = PlotFinished
 	return
 }
 
+// finish records the end of the plot with its final state, once the plotter no longer changes it, and
+// runs the hook of that state.
+func (ap *ActivePlot) finish(state int) {
+	ap.lock.Lock()
+	ap.EndTime = ap.clock.Now()
+	ap.State = state
+	ap.lock.Unlock()
+	ap.publishState()
+	switch state {
+	case PlotFinished:
+		ap.queueHook(HookOnFinish)
+	case PlotError:
+		ap.queueHook(HookOnError)
+	case PlotKilled:
+		ap.queueHook(HookOnKill)
+	}
+}
+
+// kill stops the plotter process, RunPlot then cleans up its temporary files and marks the plot killed.
+func (ap *ActivePlot) kill() {
+	ap.lock.Lock()
+	if ap.State != PlotRunning && ap.State != PlotStalled {
+		ap.lock.Unlock()
+		return
+	}
+	ap.killed = true
+	process := ap.process
+	ap.lock.Unlock()
+	if process != nil {
+		process.Kill()
+	} else if ap.simulated != nil {
+		ap.simulated.kill()
+	}
+}
+
+func (ap *ActivePlot) setState(state int) {
+	ap.lock.Lock()
+	ap.State = state
+	ap.lock.Unlock()
+	ap.publishState()
+}
+
+// switchState changes the state of the plot only if it is still from, so that the end of the plot is not
+// overwritten.
+func (ap *ActivePlot) switchState(from int, to int) bool {
+	ap.lock.Lock()
+	if ap.State != from {
+		ap.lock.Unlock()
+		return false
+	}
+	ap.State = to
+	ap.lock.Unlock()
+	ap.publishState()
+	return true
+}
+
+func (ap *ActivePlot) publishState() {
+	ap.lock.RLock()
+	ev := PlotEvent{
+		PlotId:   ap.PlotId,
+		Id:       ap.Id,
+		Kind:     PlotEventState,
+		State:    ap.State,
+		Phase:    ap.Phase,
+		Progress: ap.Progress,
+	}
+	ap.lock.RUnlock()
+	ap.publish(ev)
+}
+
+// publish sends an event about the plot, and records it as the latest version of the plot.
+func (ap *ActivePlot) publish(ev PlotEvent) {
+	if seq := ap.events.publish(ev); seq > 0 {
+		atomic.StoreUint64(&ap.version, seq)
+	}
+}
+
+func (ap *ActivePlot) getVersion() uint64 {
+	return atomic.LoadUint64(&ap.version)
+}
+
 func (ap *ActivePlot) createCmd(config *Config) (cmd string, args []string) {
 	if len(config.MadMaxPlotter) > 0 {
 		ap.useMadmaxPlotter = true
@@ -282,79 +490,108 @@ func (ap *ActivePlot) createCmd(config *Config) (cmd string, args []string) {
 	return
 }
 
+// commandLine returns the command as it would be typed in a shell.
+func commandLine(cmd string, args []string) string {
+	parts := []string{cmd}
+	for _, arg := range args {
+		if len(arg) == 0 || strings.ContainsAny(arg, " \t\"'") {
+			arg = strconv.Quote(arg)
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+var (
+	chiaComputingRegex   = regexp.MustCompile(`^Computing table (\d)`)
+	chiaCompressingRegex = regexp.MustCompile(`^Compressing tables (\d) and (\d)`)
+	chiaTableTimeRegex   = regexp.MustCompile(`^(?:F1 complete, time|Forward propagation table time|Total compress table time): ([\d.]+) seconds`)
+	chiaFinalFileRegex   = regexp.MustCompile(`^Renamed final file from ".*" to "(.*)"`)
+	madmaxTableRegex     = regexp.MustCompile(`^\[P(\d(?:-\d)?)\] Table (\d)(?: rewrite)? took ([\d.]+) sec`)
+	madmaxFinalFileRegex = regexp.MustCompile(`^(?:Started copy to|Renamed final plot to) (.*\.plot)`)
+)
+
+// parseDetails records the table timings and the final plot file reported by a log line.
+func (ap *ActivePlot) parseDetails(s string) {
+	s = strings.TrimSpace(s)
+	if ap.useMadmaxPlotter {
+		if m := madmaxTableRegex.FindStringSubmatch(s); m != nil {
+			ap.addTableTiming(fmt.Sprintf("Phase %s table %s", m[1], m[2]), m[3])
+		} else if m := madmaxFinalFileRegex.FindStringSubmatch(s); m != nil {
+			ap.FinalPath = m[1]
+		}
+		return
+	}
+	if m := chiaComputingRegex.FindStringSubmatch(s); m != nil {
+		ap.currentTables = "Phase 1 table " + m[1]
+	} else if m := chiaCompressingRegex.FindStringSubmatch(s); m != nil {
+		ap.currentTables = fmt.Sprintf("Phase 3 tables %s and %s", m[1], m[2])
+	} else if m := chiaTableTimeRegex.FindStringSubmatch(s); m != nil && len(ap.currentTables) > 0 {
+		ap.addTableTiming(ap.currentTables, m[1])
+		ap.currentTables = ""
+	} else if m := chiaFinalFileRegex.FindStringSubmatch(s); m != nil {
+		ap.FinalPath = m[1]
+	}
+}
+
+func (ap *ActivePlot) addTableTiming(name string, seconds string) {
+	if secs, err := strconv.ParseFloat(seconds, 64); err == nil {
+		ap.TableTimings = append(ap.TableTimings, TableTiming{Name: name, Seconds: secs})
+	}
+}
+
 func (ap *ActivePlot) processLogs(in io.ReadCloser) {
 	reader := bufio.NewReader(in)
-	var logFile *os.File
 	for {
 		if s, err := reader.ReadString('\n'); err != nil {
 			break
 		} else {
+			ap.lock.Lock()
+			phase, progress, id := ap.Phase, ap.Progress, ap.Id
 			if ap.useMadmaxPlotter {
 				if strings.HasPrefix(s, "Plot Name:") {
 					ap.Phase = "1/4"
 					ap.Progress = "1%"
 					ap.Id = strings.TrimSuffix(s[37:], "\n")
-					if len(ap.SavePlotLogDir) > 0 {
-						logFilePath := filepath.Join(ap.SavePlotLogDir, fmt.Sprintf("plotng_log_%s.txt", ap.Id))
-						logFile, err = os.Create(logFilePath)
-						if err != nil {
-							fmt.Sprintf("Failed to create log file [%s]: %s", logFilePath, err)
-						} else {
-							for _, l := range ap.Tail {
-								logFile.Write([]byte(l))
-							}
-						}
-					}
 				}
 				if strings.HasPrefix(s, "Phase 1 took") {
 					ap.Phase = "2/4"
 					ap.Progress = "25%"
-					ap.Phase1Time = time.Now()
+					ap.Phase1Time = ap.clock.Now()
 				}
 				if strings.HasPrefix(s, "Phase 2 took") {
 					ap.Phase = "3/4"
 					ap.Progress = "50%"
-					ap.Phase2Time = time.Now()
+					ap.Phase2Time = ap.clock.Now()
 				}
 				if strings.HasPrefix(s, "Phase 3 took") {
 					ap.Phase = "4/4"
 					ap.Progress = "75%"
-					ap.Phase3Time = time.Now()
+					ap.Phase3Time = ap.clock.Now()
 				}
 				if strings.HasPrefix(s, "Phase 4 took") {
 					ap.Phase = "cp"
 					ap.Progress = "100%"
-					ap.Phase4Time = time.Now()
+					ap.Phase4Time = ap.clock.Now()
 				}
 			} else {
 				if strings.HasPrefix(s, "Starting phase ") {
 					ap.Phase = s[15:18]
 					switch ap.Phase {
 					case "2/4":
-						ap.Phase1Time = time.Now()
+						ap.Phase1Time = ap.clock.Now()
 					case "3/4":
-						ap.Phase2Time = time.Now()
+						ap.Phase2Time = ap.clock.Now()
 					case "4/4":
-						ap.Phase3Time = time.Now()
+						ap.Phase3Time = ap.clock.Now()
 					}
 				}
 				if strings.HasPrefix(s, "Copied final file") {
 					ap.Phase = "cp"
-					ap.Phase4Time = time.Now()
+					ap.Phase4Time = ap.clock.Now()
 				}
 				if strings.HasPrefix(s, "ID: ") {
 					ap.Id = strings.TrimSuffix(s[4:], "\n")
-					if len(ap.SavePlotLogDir) > 0 {
-						logFilePath := filepath.Join(ap.SavePlotLogDir, fmt.Sprintf("plotng_log_%s.txt", ap.Id))
-						logFile, err = os.Create(logFilePath)
-						if err != nil {
-							fmt.Sprintf("Failed to create log file [%s]: %s", logFilePath, err)
-						} else {
-							for _, l := range ap.Tail {
-								logFile.Write([]byte(l))
-							}
-						}
-					}
 				}
 			}
 			for phaseStr, progress := range progressTable {
@@ -363,23 +600,134 @@ func (ap *ActivePlot) processLogs(in io.ReadCloser) {
 					break
 				}
 			}
-			ap.lock.Lock()
-			if logFile != nil {
-				logFile.Write([]byte(s))
+			ap.parseDetails(s)
+			ap.LastLogTime = ap.clock.Now()
+			phaseChanged, progressChanged, idChanged := ap.Phase != phase, ap.Progress != progress, ap.Id != id
+			ap.lock.Unlock()
+			if idChanged {
+				ap.createLog()
 			}
-			ap.Tail = append(ap.Tail, s)
-			if len(ap.Tail) > 20 {
-				ap.Tail = ap.Tail[len(ap.Tail)-20:]
+			ap.appendLog(s)
+			if phaseChanged || progressChanged {
+				ap.publishState()
+			}
+			if phaseChanged {
+				ap.queueHook(HookOnPhaseChange)
 			}
-			ap.lock.Unlock()
 		}
 	}
-	if logFile != nil {
-		logFile.Close()
-	}
 	return
 }
 
+// createLog creates the plot log file in SavePlotLogDir, seeded with the lines seen so far.
+func (ap *ActivePlot) createLog() {
+	if len(ap.SavePlotLogDir) == 0 {
+		return
+	}
+	ap.lock.Lock()
+	defer ap.lock.Unlock()
+	if ap.logFile != nil {
+		return
+	}
+	logFilePath := filepath.Join(ap.SavePlotLogDir, fmt.Sprintf("plotng_log_%s.txt", ap.Id))
+	if logFile, err := os.Create(logFilePath); err != nil {
+		log.Printf("Failed to create log file [%s]: %s", logFilePath, err)
+	} else {
+		for _, l := range ap.Tail {
+			logFile.Write([]byte(l))
+		}
+		ap.logFile = logFile
+	}
+}
+
+// openFullLog creates the file in the log store which receives every line of the plot log.
+func (ap *ActivePlot) openFullLog() {
+	if ap.logStore == nil {
+		return
+	}
+	if fullLog, err := ap.logStore.Create(ap.PlotId); err != nil {
+		log.Printf("Failed to create plot log: %s", err)
+	} else {
+		ap.fullLog = fullLog
+	}
+}
+
+// appendLog adds a line to the plot log, keeping the last 20 lines in Tail.  Past the MaxSize of the
+// log store, a runaway plotter only logs to Tail, with the lines published without an offset.
+func (ap *ActivePlot) appendLog(s string) {
+	ap.lock.Lock()
+	if ap.logFile != nil {
+		ap.logFile.Write([]byte(s))
+	}
+	line, offset := s, ap.logSize
+	if ap.logStore != nil && ap.logStore.MaxSize > 0 && ap.logSize+int64(len(s)) > ap.logStore.MaxSize {
+		line, offset = "", -1
+		if !ap.logTruncated {
+			ap.logTruncated = true
+			line, offset = fmt.Sprintf("plotng: log truncated at %d bytes\n", ap.logStore.MaxSize), ap.logSize
+		}
+	}
+	if ap.fullLog != nil {
+		ap.fullLog.Write([]byte(line))
+	}
+	ap.logSize += int64(len(line))
+	if offset < 0 {
+		line = s
+	}
+	ap.Tail = append(ap.Tail, s)
+	if len(ap.Tail) > 20 {
+		ap.Tail = ap.Tail[len(ap.Tail)-20:]
+	}
+	id := ap.Id
+	ap.lock.Unlock()
+	ap.publish(PlotEvent{
+		PlotId: ap.PlotId,
+		Id:     id,
+		Kind:   PlotEventLog,
+		Line:   line,
+		Offset: offset,
+	})
+	ap.publishTail()
+}
+
+// publishTail publishes a PlotEventTail a second after the first of the lines added to Tail since the
+// last one, so that a chatty plotter changes the version of the plot at most once per second.
+func (ap *ActivePlot) publishTail() {
+	ap.lock.Lock()
+	pending := ap.tailPending
+	ap.tailPending = true
+	ap.lock.Unlock()
+	if pending || ap.events == nil {
+		return
+	}
+	time.AfterFunc(time.Second, func() {
+		ap.lock.Lock()
+		ap.tailPending = false
+		id := ap.Id
+		ap.lock.Unlock()
+		ap.publish(PlotEvent{PlotId: ap.PlotId, Id: id, Kind: PlotEventTail})
+	})
+}
+
+func (ap *ActivePlot) closeLog() {
+	ap.lock.Lock()
+	if ap.logFile != nil {
+		ap.logFile.Close()
+		ap.logFile = nil
+	}
+	fullLog := ap.fullLog
+	ap.fullLog = nil
+	ap.lock.Unlock()
+
+	if fullLog != nil {
+		fullLog.Close()
+		if err := ap.logStore.Compress(ap.PlotId); err != nil {
+			log.Printf("Failed to compress plot log: %s", err)
+		}
+		ap.logStore.Prune(ap.clock.Now())
+	}
+}
+
 func (ap *ActivePlot) cleanup() {
 	if len(ap.Id) == 0 {
 		return
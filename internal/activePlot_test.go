@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestParseDetails(t *testing.T) {
+	chia := &ActivePlot{}
+	for _, line := range []string{
+		"Computing table 1\n",
+		"F1 complete, time: 227.5 seconds. CPU (97.59%) Mon Jun 14 10:03:47 2021\n",
+		"Computing table 2\n",
+		"\tForward propagation table time: 1105.25 seconds. CPU (165.200000%) Mon Jun 14 10:22:12 2021\n",
+		"Compressing tables 1 and 2\n",
+		"\tTotal compress table time: 349.75 seconds. CPU (98.150000%) Mon Jun 14 12:01:40 2021\n",
+		"Renamed final file from \"/dest/plot-k32-2021-06-14-09-59-abc.plot.2.tmp\" to \"/dest/plot-k32-2021-06-14-09-59-abc.plot\"\n",
+	} {
+		chia.parseDetails(line)
+	}
+	expected := []TableTiming{{"Phase 1 table 1", 227.5}, {"Phase 1 table 2", 1105.25}, {"Phase 3 tables 1 and 2", 349.75}}
+	if len(chia.TableTimings) != len(expected) {
+		t.Fatalf("unexpected table timings: %v", chia.TableTimings)
+	}
+	for i := range expected {
+		if chia.TableTimings[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], chia.TableTimings[i])
+		}
+	}
+	if chia.FinalPath != "/dest/plot-k32-2021-06-14-09-59-abc.plot" {
+		t.Errorf("unexpected final path %s", chia.FinalPath)
+	}
+
+	madmax := &ActivePlot{useMadmaxPlotter: true}
+	for _, line := range []string{
+		"[P1] Table 1 took 20.5 sec\n",
+		"[P2] Table 7 rewrite took 5.25 sec, dropped 0 entries (0 %)\n",
+		"[P3-1] Table 2 took 12 sec, wrote 3429 entries\n",
+		"Started copy to /dest/plot-k32-2021-06-14-09-59-def.plot\n",
+	} {
+		madmax.parseDetails(line)
+	}
+	expected = []TableTiming{{"Phase 1 table 1", 20.5}, {"Phase 2 table 7", 5.25}, {"Phase 3-1 table 2", 12}}
+	if len(madmax.TableTimings) != len(expected) {
+		t.Fatalf("unexpected madmax table timings: %v", madmax.TableTimings)
+	}
+	for i := range expected {
+		if madmax.TableTimings[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], madmax.TableTimings[i])
+		}
+	}
+	if madmax.FinalPath != "/dest/plot-k32-2021-06-14-09-59-def.plot" {
+		t.Errorf("unexpected madmax final path %s", madmax.FinalPath)
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	if cl := commandLine("chia", []string{"plots", "create", "-t", "/mnt/temp dir", "-k32"}); cl != `chia plots create -t "/mnt/temp dir" -k32` {
+		t.Errorf("unexpected command line %s", cl)
+	}
+}
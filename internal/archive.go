@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultArchivePageSize = 100
+	maxArchivePageSize     = 1000
+)
+
+// pruneArchive removes the plots beyond the maxCount most recently ended or which ended more than
+// maxDays ago (0 for no limit).  It returns the plots kept, in the same order, and the end time before
+// which plots were removed.
+func pruneArchive(archive []*ActivePlot, maxCount int, maxDays int, now time.Time) ([]*ActivePlot, time.Time) {
+	var cutoff time.Time
+	if maxDays > 0 {
+		cutoff = now.Add(-time.Duration(maxDays) * 24 * time.Hour)
+	}
+	if maxCount > 0 && len(archive) > maxCount {
+		ends := make([]time.Time, 0, len(archive))
+		for _, plot := range archive {
+			ends = append(ends, plot.EndTime)
+		}
+		sort.Slice(ends, func(i, j int) bool { return ends[i].Before(ends[j]) })
+		if oldest := ends[len(ends)-maxCount]; oldest.After(cutoff) {
+			cutoff = oldest
+		}
+	}
+	if cutoff.IsZero() {
+		return archive, cutoff
+	}
+	kept := archive[:0]
+	for _, plot := range archive {
+		if !plot.EndTime.Before(cutoff) {
+			kept = append(kept, plot)
+		}
+	}
+	for i := len(kept); i < len(archive); i++ {
+		archive[i] = nil
+	}
+	return kept, cutoff
+}
+
+// pruneArchive applies the ArchiveMaxCount and ArchiveMaxDays limits.  Must be called with the server
+// lock held.
+func (server *Server) pruneArchive(config *Config, now time.Time) {
+	count := len(server.archive)
+	archive, cutoff := pruneArchive(server.archive, config.ArchiveMaxCount, config.ArchiveMaxDays, now)
+	server.archive = archive
+	if len(archive) != count {
+		// clients drop the plots which ended before the cutoff when applying the next delta
+		server.archiveCutoff = cutoff
+		server.events.publish(PlotEvent{Kind: PlotEventServer})
+	}
+}
+
+// archivePage is a page of the archived plots matching a filter, sorted by start time.
+type archivePage struct {
+	Total   int // plots matching the filter
+	Offset  int
+	Records []archiveRecord
+}
+
+// serveArchivePage handles /archive?from=&to=&dir=&offset=&limit=, collect returns the records matching
+// the filter.
+func serveArchivePage(resp http.ResponseWriter, req *http.Request, collect func(filter archiveFilter) []archiveRecord) {
+	query := req.URL.Query()
+	filter, err := parseArchiveFilter(query)
+	if err != nil {
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	offset, limit := 0, defaultArchivePageSize
+	if s := query.Get("offset"); len(s) > 0 {
+		if offset, err = strconv.Atoi(s); err != nil || offset < 0 {
+			http.Error(resp, "invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := query.Get("limit"); len(s) > 0 {
+		if limit, err = strconv.Atoi(s); err != nil || limit <= 0 {
+			http.Error(resp, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		if limit > maxArchivePageSize {
+			limit = maxArchivePageSize
+		}
+	}
+	records := collect(filter)
+	page := archivePage{Total: len(records), Offset: offset, Records: []archiveRecord{}}
+	if offset < len(records) {
+		end := offset + limit
+		if end > len(records) {
+			end = len(records)
+		}
+		page.Records = records[offset:end]
+	}
+	writeJSON(resp, page)
+}
@@ -0,0 +1,186 @@
+package internal
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// archiveRecord is an archived plot as exported for analysis.  Durations are in seconds, and 0 when
+// the plot did not reach the end of the phase.
+type archiveRecord struct {
+	Host       string
+	PlotId     int64
+	Id         string
+	PlotDir    string
+	TargetDir  string
+	Tmp2Dir    string
+	PlotSize   int
+	Threads    int
+	Buffers    int
+	BucketSize int
+	StartTime  time.Time
+	EndTime    time.Time
+	Phase1Secs int64
+	Phase2Secs int64
+	Phase3Secs int64
+	Phase4Secs int64
+	CopySecs   int64
+	TotalSecs  int64
+	Outcome    string
+}
+
+func newArchiveRecord(host string, plot *ActivePlot) archiveRecord {
+	record := archiveRecord{
+		Host:       host,
+		PlotId:     plot.PlotId,
+		Id:         plot.Id,
+		PlotDir:    plot.PlotDir,
+		TargetDir:  plot.TargetDir,
+		Tmp2Dir:    plot.Tmp2Dir,
+		PlotSize:   plot.PlotSize,
+		Threads:    plot.Threads,
+		Buffers:    plot.Buffers,
+		BucketSize: plot.BucketSize,
+		StartTime:  plot.StartTime,
+		EndTime:    plot.EndTime,
+		Outcome:    stateString(plot.State),
+	}
+	var secs [phaseCount]int64
+	for phase := 1; phase <= phaseCount; phase++ {
+		start, end := plot.getPhaseTime(phase-1), plot.getPhaseTime(phase)
+		if !start.IsZero() && !end.IsZero() && end.After(start) {
+			secs[phase-1] = int64(end.Sub(start) / time.Second)
+		}
+	}
+	record.Phase1Secs, record.Phase2Secs, record.Phase3Secs, record.Phase4Secs, record.CopySecs = secs[0], secs[1], secs[2], secs[3], secs[4]
+	if !plot.EndTime.IsZero() {
+		record.TotalSecs = int64(plot.EndTime.Sub(plot.StartTime) / time.Second)
+	}
+	return record
+}
+
+// archiveFilter selects the archived plots started in [From, To) using Dir as temp, tmp2 or dest directory.
+type archiveFilter struct {
+	From time.Time
+	To   time.Time
+	Dir  string
+}
+
+// parseArchiveFilter reads the from, to and dir query parameters, dates are either 2006-01-02 or RFC 3339.
+func parseArchiveFilter(query url.Values) (filter archiveFilter, err error) {
+	if filter.From, err = parseExportDate(query.Get("from")); err != nil {
+		return filter, fmt.Errorf("invalid from: %w", err)
+	}
+	if filter.To, err = parseExportDate(query.Get("to")); err != nil {
+		return filter, fmt.Errorf("invalid to: %w", err)
+	}
+	filter.Dir = query.Get("dir")
+	return filter, nil
+}
+
+func parseExportDate(s string) (time.Time, error) {
+	if len(s) == 0 {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func (filter archiveFilter) match(plot *ActivePlot) bool {
+	if plot.StartTime.Before(filter.From) || (!filter.To.IsZero() && !plot.StartTime.Before(filter.To)) {
+		return false
+	}
+	dir := strings.TrimRight(filter.Dir, "/\\")
+	return len(dir) == 0 || strings.TrimRight(plot.PlotDir, "/\\") == dir || strings.TrimRight(plot.TargetDir, "/\\") == dir ||
+		strings.TrimRight(plot.Tmp2Dir, "/\\") == dir
+}
+
+// exportArchive selects the archived plots of every host matching the filter, sorted by start time.
+func exportArchive(archives map[string][]*ActivePlot, filter archiveFilter) []archiveRecord {
+	records := []archiveRecord{}
+	for host, archive := range archives {
+		for _, plot := range archive {
+			if filter.match(plot) {
+				records = append(records, newArchiveRecord(host, plot))
+			}
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if !records[i].StartTime.Equal(records[j].StartTime) {
+			return records[i].StartTime.Before(records[j].StartTime)
+		}
+		return records[i].Host < records[j].Host
+	})
+	return records
+}
+
+// writeArchiveRecords writes the records as CSV or JSON.
+func writeArchiveRecords(w io.Writer, format string, records []archiveRecord) error {
+	switch format {
+	case FormatJSON:
+		return writeJSONObject(w, records)
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		t := reflect.TypeOf(archiveRecord{})
+		var header []string
+		for i := 0; i < t.NumField(); i++ {
+			header = append(header, t.Field(i).Name)
+		}
+		cw.Write(header)
+		for _, record := range records {
+			v := reflect.ValueOf(record)
+			var values []string
+			for i := 0; i < v.NumField(); i++ {
+				if tm, ok := v.Field(i).Interface().(time.Time); ok {
+					if tm.IsZero() {
+						values = append(values, "")
+					} else {
+						values = append(values, tm.Format(time.RFC3339))
+					}
+				} else {
+					values = append(values, fmt.Sprint(v.Field(i).Interface()))
+				}
+			}
+			cw.Write(values)
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown format %s, expected csv or json", format)
+}
+
+// serveArchiveExport handles /archive/export?format=csv|json&from=&to=&dir=, collect returns the
+// records matching the filter.
+func serveArchiveExport(resp http.ResponseWriter, req *http.Request, collect func(filter archiveFilter) []archiveRecord) {
+	query := req.URL.Query()
+	filter, err := parseArchiveFilter(query)
+	if err != nil {
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := query.Get("format")
+	switch format {
+	case "", FormatCSV:
+		format = FormatCSV
+		resp.Header().Set("Content-Type", "text/csv")
+	case FormatJSON:
+		resp.Header().Set("Content-Type", "application/json")
+	default:
+		http.Error(resp, fmt.Sprintf("unknown format %s, expected csv or json", format), http.StatusBadRequest)
+		return
+	}
+	resp.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=plotng-archive.%s", format))
+	if err := writeArchiveRecords(resp, format, collect(filter)); err != nil {
+		log.Printf("Failed to export archive: %s", err)
+	}
+}
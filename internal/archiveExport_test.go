@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestArchiveExport(t *testing.T) {
+	start := time.Date(2021, 6, 1, 10, 0, 0, 0, time.Local)
+	finished := &ActivePlot{
+		PlotId: 1, Id: "finished", PlotDir: "/tmp1/", TargetDir: "/dest1", PlotSize: 32, Threads: 4, State: PlotFinished,
+		StartTime:  start,
+		Phase1Time: start.Add(3 * time.Hour),
+		Phase2Time: start.Add(5 * time.Hour),
+		Phase3Time: start.Add(8 * time.Hour),
+		Phase4Time: start.Add(9 * time.Hour),
+		EndTime:    start.Add(9*time.Hour + 10*time.Minute),
+	}
+	failed := &ActivePlot{
+		PlotId: 2, Id: "failed", PlotDir: "/tmp2", TargetDir: "/dest1", State: PlotError,
+		StartTime:  start.Add(48 * time.Hour),
+		Phase1Time: start.Add(50 * time.Hour),
+		EndTime:    start.Add(51 * time.Hour),
+	}
+	svr := &Server{
+		config:   &PlotConfig{},
+		archive:  []*ActivePlot{finished, failed},
+		notifier: &Notifier{Host: "plotter1"},
+	}
+
+	resp := httptest.NewRecorder()
+	svr.ServeHTTP(resp, httptest.NewRequest("GET", "/archive/export?format=json", nil))
+	var records []archiveRecord
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil || len(records) != 2 {
+		t.Fatalf("unexpected export: %+v err=%v", records, err)
+	}
+	expected := archiveRecord{
+		Host: "plotter1", PlotId: 1, Id: "finished", PlotDir: "/tmp1/", TargetDir: "/dest1", PlotSize: 32, Threads: 4,
+		Phase1Secs: 3 * 3600, Phase2Secs: 2 * 3600, Phase3Secs: 3 * 3600, Phase4Secs: 3600, CopySecs: 600, TotalSecs: 9*3600 + 600,
+		Outcome: "Finished",
+	}
+	records[0].StartTime, records[0].EndTime = time.Time{}, time.Time{}
+	if records[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, records[0])
+	}
+	if records[1].Phase1Secs != 2*3600 || records[1].Phase2Secs != 0 || records[1].Outcome != "Errored" {
+		t.Errorf("unexpected failed plot: %+v", records[1])
+	}
+
+	for query, ids := range map[string][]string{
+		"from=2021-06-02":            {"failed"},
+		"to=2021-06-02":              {"finished"},
+		"dir=/tmp1":                  {"finished"},
+		"dir=/dest1&from=2021-06-01": {"finished", "failed"},
+		"from=" + url.QueryEscape(start.Add(time.Hour).Format(time.RFC3339)): {"failed"},
+	} {
+		resp := httptest.NewRecorder()
+		svr.ServeHTTP(resp, httptest.NewRequest("GET", "/archive/export?format=csv&"+query, nil))
+		rows, err := csv.NewReader(resp.Body).ReadAll()
+		if err != nil || len(rows) != len(ids)+1 {
+			t.Errorf("%s: unexpected rows %v err=%v", query, rows, err)
+			continue
+		}
+		for i, id := range ids {
+			if rows[i+1][2] != id {
+				t.Errorf("%s: expected %s, got %s", query, id, rows[i+1][2])
+			}
+		}
+	}
+
+	resp = httptest.NewRecorder()
+	svr.ServeHTTP(resp, httptest.NewRequest("GET", "/archive/export?from=yesterday", nil))
+	if resp.Code != 400 {
+		t.Errorf("invalid date: expected 400, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPruneArchive(t *testing.T) {
+	now := initialTime
+	var archive []*ActivePlot
+	for i := 1; i <= 5; i++ {
+		archive = append(archive, &ActivePlot{PlotId: int64(i), EndTime: now.Add(-time.Duration(6-i) * 24 * time.Hour)})
+	}
+	ids := func(plots []*ActivePlot) (ids []int64) {
+		for _, plot := range plots {
+			ids = append(ids, plot.PlotId)
+		}
+		return
+	}
+
+	if kept, cutoff := pruneArchive(append([]*ActivePlot{}, archive...), 0, 0, now); len(kept) != 5 || !cutoff.IsZero() {
+		t.Errorf("no limit: unexpected %v %s", ids(kept), cutoff)
+	}
+	if kept, cutoff := pruneArchive(append([]*ActivePlot{}, archive...), 2, 0, now); len(kept) != 2 || kept[0].PlotId != 4 || !cutoff.Equal(archive[3].EndTime) {
+		t.Errorf("max count: unexpected %v %s", ids(kept), cutoff)
+	}
+	if kept, _ := pruneArchive(append([]*ActivePlot{}, archive...), 0, 3, now); len(kept) != 3 || kept[0].PlotId != 3 {
+		t.Errorf("max days: unexpected %v", ids(kept))
+	}
+	if kept, _ := pruneArchive(append([]*ActivePlot{}, archive...), 4, 2, now); len(kept) != 2 || kept[0].PlotId != 4 {
+		t.Errorf("both limits: unexpected %v", ids(kept))
+	}
+
+	// clients applying a delta drop the pruned plots
+	full := &Msg{Archived: archive, Version: 1}
+	merged := full.apply(&Msg{Delta: true, Version: 2, ArchiveCutoff: archive[3].EndTime})
+	if len(merged.Archived) != 2 {
+		t.Errorf("expected the pruned plots to be dropped, got %v", ids(merged.Archived))
+	}
+}
+
+func TestArchivePage(t *testing.T) {
+	start := time.Date(2021, 6, 1, 10, 0, 0, 0, time.Local)
+	svr := &Server{
+		config:   &PlotConfig{},
+		notifier: &Notifier{Host: "plotter1"},
+	}
+	for i := 1; i <= 5; i++ {
+		plotStart := start.Add(time.Duration(i) * 24 * time.Hour)
+		svr.archive = append(svr.archive, &ActivePlot{PlotId: int64(i), State: PlotFinished, StartTime: plotStart, EndTime: plotStart.Add(time.Hour)})
+	}
+	for query, expected := range map[string][]int64{
+		"":                                 {1, 2, 3, 4, 5},
+		"limit=2":                          {1, 2},
+		"offset=4&limit=2":                 {5},
+		"offset=10":                        {},
+		"from=2021-06-03&to=2021-06-05":    {2, 3},
+		"from=2021-06-03&offset=1&limit=1": {3},
+	} {
+		resp := httptest.NewRecorder()
+		svr.ServeHTTP(resp, httptest.NewRequest("GET", "/archive?"+query, nil))
+		var page archivePage
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			t.Errorf("%s: %s", query, err)
+			continue
+		}
+		var ids []int64
+		for _, record := range page.Records {
+			ids = append(ids, record.PlotId)
+		}
+		if len(ids) != len(expected) {
+			t.Errorf("%s: expected %v, got %v", query, expected, ids)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != expected[i] {
+				t.Errorf("%s: expected %v, got %v", query, expected, ids)
+				break
+			}
+		}
+	}
+
+	resp := httptest.NewRecorder()
+	svr.ServeHTTP(resp, httptest.NewRequest("GET", "/archive?from=2021-06-03", nil))
+	var page archivePage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil || page.Total != 4 {
+		t.Errorf("expected 4 plots in total, got %d %v", page.Total, err)
+	}
+	resp = httptest.NewRecorder()
+	svr.ServeHTTP(resp, httptest.NewRequest("GET", "/archive?limit=-1", nil))
+	if resp.Code != 400 {
+		t.Errorf("invalid limit: expected 400, got %d", resp.Code)
+	}
+}
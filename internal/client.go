@@ -1,10 +1,12 @@
 package internal
 
 import (
+	"context"
 	"encoding/gob"
 	"fmt"
 	"math"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -23,14 +25,35 @@ type Client struct {
 	hostsTable         *widget.SortedTable
 
 	logTextbox          *tview.TextView
-	hosts               []string
+	statsView           *tview.TextView
+	mainPanel           tview.Primitive
+	panelOrder          []string
+	config              *ClientConfig
+	detailView          *tview.TextView
+	schedulerView       *tview.TextView
+	schedulerHost       string
+	schedulerLoaded     time.Time
+	detailPlotId        string
+	pages               *tview.Pages
+	modalFocus          tview.Primitive
+	hosts               map[string]*clientHost
+	hostsFile           *HostsFile
 	msg                 map[string]*Msg
+	hostStatus          map[string]hostStatus
 	archivedTableActive bool
 	activeLogs          map[string][]string
 	archivedLogs        map[string][]string
+	plotHosts           map[string]string
 	logPlotId           string
+	fullLogPlotId       string
+	fullLogSize         int64
+	logStreaming        bool
+	logCancel           context.CancelFunc
 
 	AlternateMouse bool
+	HostsFile      string // JSON list of HostEntry, reloaded when it changes
+	Discover       bool   // add the plotters announcing themselves on the LAN
+	ConfigFile     string // JSON ClientConfig, saved when the layout is changed
 }
 
 var httpClient = &http.Client{
@@ -38,30 +61,67 @@ var httpClient = &http.Client{
 }
 
 func (client *Client) ProcessLoop(hostList string) {
-	for _, host := range strings.Split(hostList, ",") {
-		host = strings.TrimSpace(host)
-		if strings.Index(host, ":") < 0 {
-			host += ":8484"
-		}
-		client.hosts = append(client.hosts, host)
+	entries, fileEntries, err := client.initialHosts(hostList)
+	if err != nil {
+		fmt.Println(err)
+		return
 	}
+	client.hosts = map[string]*clientHost{}
 	client.msg = map[string]*Msg{}
+	client.plotHosts = map[string]string{}
+	client.hostStatus = map[string]hostStatus{}
+
+	if client.config, err = loadClientConfig(client.ConfigFile); err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	gob.Register(Msg{})
 	gob.Register(ActivePlot{})
 
-	client.setupUI(len(client.hosts))
+	client.setupUI(len(entries) + len(fileEntries))
 
 	if err := client.configureMouse(); err != nil {
 		fmt.Printf("unable to setup screen: %v", err)
 		return
 	}
 
-	go client.processLoop()
+	for _, entry := range entries {
+		client.addHost(entry, hostSourceFlag)
+	}
+	for _, entry := range fileEntries {
+		client.addHost(entry, hostSourceFile)
+	}
+	go client.refreshHostsTable()
+	if client.hostsFile != nil {
+		go client.watchHostsFile()
+	}
+	if client.Discover {
+		go client.discoverHosts()
+	}
 
 	client.app.Run()
 }
 
+// initialHosts returns the hosts given on the command line and in the hosts file, defaulting to localhost.
+func (client *Client) initialHosts(hostList string) (flagHosts []HostEntry, fileHosts []HostEntry, err error) {
+	for _, host := range strings.Split(hostList, ",") {
+		if host = strings.TrimSpace(host); len(host) > 0 {
+			flagHosts = append(flagHosts, HostEntry{Address: host})
+		}
+	}
+	if len(client.HostsFile) > 0 {
+		client.hostsFile = &HostsFile{Path: client.HostsFile}
+		if fileHosts, _, err = client.hostsFile.Load(); err != nil {
+			return nil, nil, fmt.Errorf("unable to load hosts file [%s]: %w", client.HostsFile, err)
+		}
+	}
+	if len(flagHosts) == 0 && len(fileHosts) == 0 && !client.Discover {
+		flagHosts = append(flagHosts, HostEntry{Address: "localhost"})
+	}
+	return flagHosts, fileHosts, nil
+}
+
 func (client *Client) configureMouse() error {
 	// tcell.Screen exposes an EnableMouse with the option to specify which type of mouse events to
 	// capture (click, drag, all).  Unfortunately putty doesn't currently support "all", which is the
@@ -88,102 +148,49 @@ func (client *Client) configureMouse() error {
 	return nil
 }
 
-func (client *Client) processLoop() {
-	client.checkServers()
-	ticker := time.NewTicker(30 * time.Second)
-	for range ticker.C {
-		client.checkServers()
-	}
-}
-
-func (client *Client) checkServers() {
-	for _, host := range client.hosts {
-		client.checkServer(host)
-	}
-}
-
-func (client *Client) getServerData(host string) (*Msg, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/", host), nil)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp, err := httpClient.Do(req); err == nil {
-		defer resp.Body.Close()
-		var msg Msg
-		decoder := gob.NewDecoder(resp.Body)
-		if err := decoder.Decode(&msg); err == nil {
-			return &msg, nil
-		} else {
-			return nil, fmt.Errorf("Failed to decode message: %w", err)
+// updateHost redraws the tables with the latest Msg from a host.  Must be called on the tview thread.
+func (client *Client) updateHost(host string, msg *Msg, status hostStatus) {
+	client.hostStatus[host] = status
+	if status.Err != nil {
+		if _, ok := client.msg[host]; !ok {
+			client.msg[host] = &Msg{}
 		}
+		client.drawHostsTable()
+		return
+	}
+	if msg.Hosts != nil {
+		client.updateHub(host, msg)
 	} else {
-		return nil, err
+		client.setMsg(host, msg)
 	}
-}
-
-func (client *Client) checkServer(host string) {
-	// Retrieve data on the goroutine thread
-	msg, err := client.getServerData(host)
+	client.drawTables()
+	client.loadSchedulerLog()
 
-	// Modify UI state on the tview thread.
-	client.app.QueueUpdateDraw(func() {
-		if err != nil {
-			if _, ok := client.msg[host]; !ok {
-				client.msg[host] = &Msg{}
-			}
-			client.msg[host].Status = err.Error()
-			client.drawHostsTable()
-			return
-		}
-		client.msg[host] = msg
-		client.drawActivePlotsTable()
-		client.drawPlotDirsTable()
-		client.drawDestDirsTable()
-		client.drawArchivedPlotsTable()
-		client.drawHostsTable()
-
-		log, ok := client.activeLogs[client.logPlotId]
-		if !ok {
-			log, ok = client.archivedLogs[client.logPlotId]
-		}
-		if ok {
-			client.logTextbox.SetTitle(fmt.Sprintf(" Log (%s) ", shortenPlotId(client.logPlotId)))
-			client.logTextbox.SetText(strings.Join(log, ""))
-			client.logTextbox.ScrollToEnd()
+	if client.fullLogPlotId == client.logPlotId {
+		if !client.logStreaming {
+			client.loadPlotLog(client.logPlotId)
 		}
-	})
-}
-
-func (client *Client) tabBetweenTables(event *tcell.EventKey) *tcell.EventKey {
-	if event.Key() != tcell.KeyTab {
-		return event
-	}
-	if client.activePlotsTable.HasFocus() {
-		client.plotDirsTable.SetFocus(client.app)
-		return nil
-	}
-	if client.plotDirsTable.HasFocus() {
-		client.destDirsTable.SetFocus(client.app)
-		return nil
-	}
-	if client.destDirsTable.HasFocus() {
-		client.archivedPlotsTable.SetFocus(client.app)
-		return nil
-	}
-	if client.archivedPlotsTable.HasFocus() {
-		client.hostsTable.SetFocus(client.app)
-		return nil
+		return
 	}
-	if client.hostsTable.HasFocus() {
-		client.app.SetFocus(client.logTextbox)
-		return nil
+	log, ok := client.activeLogs[client.logPlotId]
+	if !ok {
+		log, ok = client.archivedLogs[client.logPlotId]
 	}
-	if client.logTextbox.HasFocus() {
-		client.activePlotsTable.SetFocus(client.app)
-		return nil
+	if ok {
+		client.logTextbox.SetTitle(fmt.Sprintf(" Log (%s) ", shortenPlotId(client.logPlotId)))
+		client.logTextbox.SetText(strings.Join(log, ""))
+		client.logTextbox.ScrollToEnd()
 	}
-	return event
+}
+
+func (client *Client) drawTables() {
+	client.drawActivePlotsTable()
+	client.drawPlotDirsTable()
+	client.drawDestDirsTable()
+	client.drawArchivedPlotsTable()
+	client.drawHostsTable()
+	client.drawStats()
+	client.drawPlotDetail()
 }
 
 func (client *Client) setupUI(hostCount int) {
@@ -196,7 +203,7 @@ func (client *Client) setupUI(hostCount int) {
 	client.activePlotsTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse))
 	client.activePlotsTable.SetSelectionChangedFunc(client.selectActivePlot)
 	client.activePlotsTable.SetupFromType(activePlotsData{})
-	client.activePlotsTable.SetInputCapture(client.tabBetweenTables)
+	client.activePlotsTable.SetInputCapture(client.activeTableKeys)
 
 	client.plotDirsTable = widget.NewSortedTable()
 	client.plotDirsTable.SetSelectable(true)
@@ -205,7 +212,7 @@ func (client *Client) setupUI(hostCount int) {
 	client.plotDirsTable.SetTitle(" Plot Directories ")
 	client.plotDirsTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse))
 	client.plotDirsTable.SetupFromType(plotDirData{})
-	client.plotDirsTable.SetInputCapture(client.tabBetweenTables)
+	client.plotDirsTable.SetInputCapture(client.tableKeys)
 
 	client.destDirsTable = widget.NewSortedTable()
 	client.destDirsTable.SetSelectable(true)
@@ -214,7 +221,7 @@ func (client *Client) setupUI(hostCount int) {
 	client.destDirsTable.SetTitle(" Dest Directories ")
 	client.destDirsTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse))
 	client.destDirsTable.SetupFromType(destDirData{})
-	client.destDirsTable.SetInputCapture(client.tabBetweenTables)
+	client.destDirsTable.SetInputCapture(client.tableKeys)
 
 	client.archivedPlotsTable = widget.NewSortedTable()
 	client.archivedPlotsTable.SetSelectable(true)
@@ -224,7 +231,7 @@ func (client *Client) setupUI(hostCount int) {
 	client.archivedPlotsTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse))
 	client.archivedPlotsTable.SetSelectionChangedFunc(client.selectArchivedPlot)
 	client.archivedPlotsTable.SetupFromType(archivedPlotData{})
-	client.archivedPlotsTable.SetInputCapture(client.tabBetweenTables)
+	client.archivedPlotsTable.SetInputCapture(client.archivedTableKeys)
 
 	client.hostsTable = widget.NewSortedTable()
 	client.hostsTable.SetSelectable(true)
@@ -233,35 +240,51 @@ func (client *Client) setupUI(hostCount int) {
 	client.hostsTable.SetTitle(" Hosts ")
 	client.hostsTable.SetSelectedStyle(tcell.StyleDefault.Attributes(tcell.AttrReverse))
 	client.hostsTable.SetupFromType(hostsData{})
-	client.hostsTable.SetInputCapture(client.tabBetweenTables)
+	client.hostsTable.SetSelectionChangedFunc(client.selectHost)
+	client.hostsTable.SetInputCapture(client.hostsTableKeys)
 
 	client.logTextbox = tview.NewTextView()
 	client.logTextbox.SetBorder(true).SetTitle(" Log ").SetTitleAlign(tview.AlignLeft)
-	client.logTextbox.SetInputCapture(client.tabBetweenTables)
+	client.logTextbox.SetInputCapture(client.panelKeys)
 
 	client.logTextbox.ScrollToEnd()
 
+	client.schedulerView = tview.NewTextView()
+	client.schedulerView.SetBorder(true).SetTitle(" Scheduler ").SetTitleAlign(tview.AlignLeft)
+	client.schedulerView.SetText("Select a host to show why its plots were started or not")
+	client.schedulerView.SetInputCapture(client.panelKeys)
+
 	client.app = tview.NewApplication()
 
-	dirPanel := tview.NewFlex()
-	dirPanel.SetDirection(tview.FlexColumn)
-	dirPanel.AddItem(client.plotDirsTable, 0, 1, false)
-	dirPanel.AddItem(client.destDirsTable, 0, 1, false)
+	client.pages = tview.NewPages()
+
+	client.app = tview.NewApplication()
+	client.app.SetRoot(client.pages, true)
+	client.layoutPanels(hostCount)
+}
 
-	mainPanel := tview.NewFlex()
-	mainPanel.SetDirection(tview.FlexRow)
-	mainPanel.AddItem(client.activePlotsTable, 0, 1, true)
-	mainPanel.AddItem(dirPanel, 0, 1, false)
-	mainPanel.AddItem(client.archivedPlotsTable, 0, 1, false)
-	if hostCount > 4 { // hosts is low priority, so keep the screen space usage low
-		hostCount = 4
+// showModal shows p centered over the tables until closeModal is called.
+func (client *Client) showModal(name string, p tview.Primitive, width, height int) {
+	if client.modalFocus == nil {
+		client.modalFocus = client.app.GetFocus()
 	}
-	const extraRows = 1 + 1 + 1 // border + header row + border
-	mainPanel.AddItem(client.hostsTable, hostCount+extraRows, 0, false)
-	mainPanel.AddItem(client.logTextbox, 0, 1, false)
+	modal := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, height, 0, true).
+			AddItem(nil, 0, 1, false), width, 0, true).
+		AddItem(nil, 0, 1, false)
+	client.pages.AddPage(name, modal, true, true)
+	client.app.SetFocus(p)
+}
 
-	client.app = tview.NewApplication()
-	client.app.SetRoot(mainPanel, true)
+func (client *Client) closeModal(name string) {
+	client.pages.RemovePage(name)
+	if client.pages.GetPageCount() == 1 && client.modalFocus != nil {
+		client.app.SetFocus(client.modalFocus)
+		client.modalFocus = nil
+	}
 }
 
 func shortenPlotId(id string) string {
@@ -276,33 +299,33 @@ func shortenPlotId(id string) string {
 type activePlotsData struct {
 	Host      string        `header:"Host"`
 	PlotId    string        `header:"Plot ID"`
-	Status    int           `header:"Status"`
+	Status    int           `header:"Status" format:"state"`
 	Phase     string        `header:"Phase"    data-align:"right"`
 	Progress  int           `header:"Progress" data-align:"right"`
 	StartTime time.Time     `header:"Start Time"`
 	Duration  time.Duration `header:"Duration"`
+	ETA       time.Duration `header:"ETA" data-align:"right"`
+	FinishAt  time.Time     `header:"Finish At"`
 	PlotDir   string        `header:"Plot Dir"`
 	DestDir   string        `header:"Dest Dir"`
 }
 
 func (apd *activePlotsData) Strings() []string {
-	status := "Unknown"
-	switch apd.Status {
-	case PlotRunning:
-		status = "Running"
-	case PlotError:
-		status = "Errored"
-	case PlotFinished:
-		status = "Finished"
+	eta, finishAt := "", ""
+	if !apd.FinishAt.IsZero() {
+		eta = DurationString(apd.ETA)
+		finishAt = apd.FinishAt.Format("2006-01-02 15:04")
 	}
 	return []string{
 		apd.Host,
 		shortenPlotId(apd.PlotId),
-		status,
+		stateString(apd.Status),
 		apd.Phase,
 		fmt.Sprintf("%d%%", apd.Progress),
 		apd.StartTime.Format("2006-01-02 15:04:05"),
 		DurationString(apd.Duration),
+		eta,
+		finishAt,
 		apd.PlotDir,
 		apd.DestDir,
 	}
@@ -317,6 +340,12 @@ func (client *Client) makeActivePlotsData(host string, p *ActivePlot) *activePlo
 	apd.Progress = p.getProgress()
 	apd.StartTime = p.getPhaseTime(0)
 	apd.Duration = time.Since(apd.StartTime)
+	if remaining, ok := client.forecastsOf(host).remainingAt(p, time.Now()); ok {
+		apd.ETA = remaining
+		apd.FinishAt = time.Now().Add(remaining)
+	} else {
+		apd.ETA = -1 // unknown
+	}
 	apd.PlotDir = p.PlotDir
 	apd.DestDir = p.TargetDir
 	return apd
@@ -335,6 +364,7 @@ func (client *Client) drawActivePlotsTable() {
 		for _, plot := range msg.Actives {
 			delete(keysToRemove, plot.Id)
 			client.activeLogs[plot.Id] = plot.Tail
+			client.plotHosts[plot.Id] = host
 			client.activePlotsTable.SetRowData(plot.Id, client.makeActivePlotsData(host, plot))
 			activePlotsCount++
 		}
@@ -344,7 +374,107 @@ func (client *Client) drawActivePlotsTable() {
 		client.activePlotsTable.ClearRowData(key)
 	}
 
-	client.activePlotsTable.SetTitle(fmt.Sprintf(" Active Plots [%d] ", activePlotsCount))
+	title := fmt.Sprintf(" Active Plots [%d] ", activePlotsCount)
+	if host, dir, at := client.nextDiskFull(time.Now()); !at.IsZero() {
+		title += fmt.Sprintf("- next disk full: %s:%s at %s ", host, dir, at.Format("2006-01-02 15:04"))
+	}
+	client.activePlotsTable.SetTitle(title)
+}
+
+// nextDiskFull returns the destination directory expected to be full first, ignoring those already full.
+func (client *Client) nextDiskFull(now time.Time) (host string, dir string, at time.Time) {
+	for h, msg := range client.msg {
+		for d, available := range msg.TargetDirs {
+			if available < PLOT_SIZE {
+				continue
+			}
+			full := client.forecastsOf(h).dirs[d].FullAt
+			if !full.IsZero() && (at.IsZero() || full.Before(at)) {
+				host, dir, at = h, d, full
+			}
+		}
+	}
+	return
+}
+
+// hostForecasts are the forecasts of a host, computed once per Msg as they scan its whole archive, the
+// tables redrawn every second only formatting them.
+type hostForecasts struct {
+	at        time.Time
+	dirs      map[string]diskForecast // by destination directory
+	remaining map[int64]time.Duration // time left of the active plots with a history, by PlotId
+}
+
+func newHostForecasts(msg *Msg, now time.Time) *hostForecasts {
+	forecasts := &hostForecasts{
+		at:        now,
+		dirs:      map[string]diskForecast{},
+		remaining: map[int64]time.Duration{},
+	}
+	for dir, available := range msg.TargetDirs {
+		forecasts.dirs[dir] = forecastDiskFull(dir, available, msg.Actives, msg.Archived, now)
+	}
+	for _, plot := range msg.Actives {
+		if remaining, ok := estimateRemaining(msg.Archived, plot, now); ok {
+			forecasts.remaining[plot.PlotId] = remaining
+		}
+	}
+	return forecasts
+}
+
+// remainingAt returns how long an active plot is expected to take at now before it finishes, and false
+// when there is no history to go by.
+func (forecasts *hostForecasts) remainingAt(plot *ActivePlot, now time.Time) (time.Duration, bool) {
+	remaining, ok := forecasts.remaining[plot.PlotId]
+	if !ok {
+		return 0, false
+	}
+	if remaining -= now.Sub(forecasts.at); remaining < 0 {
+		remaining = 0 // running late, assume the plot is about to end
+	}
+	return remaining, true
+}
+
+// setMsg stores the latest Msg of a host with its forecasts.
+func (client *Client) setMsg(host string, msg *Msg) {
+	client.msg[host] = msg
+	if h, ok := client.hosts[host]; ok {
+		h.forecasts = newHostForecasts(msg, time.Now())
+	}
+}
+
+// forecastsOf returns the forecasts of a host as of its latest Msg.
+func (client *Client) forecastsOf(host string) *hostForecasts {
+	if h, ok := client.hosts[host]; ok && h.forecasts != nil {
+		return h.forecasts
+	}
+	return &hostForecasts{}
+}
+
+// diskFullWarning is how early the hosts table warns about destination directories becoming full.
+const diskFullWarning = 24 * time.Hour
+
+// diskFullStatus returns a warning about the destination directories of a host which are full or
+// expected to be full soon, or "" if there are none.
+func diskFullStatus(msg *Msg, forecasts *hostForecasts, now time.Time) string {
+	var full, soon []string
+	for dir, available := range msg.TargetDirs {
+		if available < PLOT_SIZE {
+			full = append(full, dir)
+		} else if at := forecasts.dirs[dir].FullAt; !at.IsZero() && at.Sub(now) < diskFullWarning {
+			soon = append(soon, fmt.Sprintf("%s in %s", dir, DurationString(at.Sub(now))))
+		}
+	}
+	sort.Strings(full)
+	sort.Strings(soon)
+	var warnings []string
+	if len(full) > 0 {
+		warnings = append(warnings, "full: "+strings.Join(full, ", "))
+	}
+	if len(soon) > 0 {
+		warnings = append(warnings, "full soon: "+strings.Join(soon, ", "))
+	}
+	return strings.Join(warnings, "; ")
 }
 
 func (client *Client) selectActivePlot(key string) {
@@ -358,6 +488,8 @@ func (client *Client) selectActivePlot(key string) {
 	} else {
 		client.logTextbox.SetText("")
 	}
+	client.fullLogPlotId = ""
+	client.followPlotLog(key)
 }
 
 // Plot directories
@@ -468,9 +600,17 @@ type destDirData struct {
 	AvgPlotTime    time.Duration `header:"Avg Plot Time" data-align:"right"`
 	Count          int           `header:"Count" data-align:"right"`
 	Failed         int           `header:"Failed" data-align:"right"`
+	InFlight       int           `header:"In Flight" data-align:"right"`
+	FullAt         time.Time     `header:"Full At"`
 }
 
 func (ddd *destDirData) Strings() []string {
+	fullAt := ""
+	if ddd.AvailableBytes < PLOT_SIZE {
+		fullAt = "full"
+	} else if !ddd.FullAt.IsZero() && ddd.AvailableBytes != math.MaxUint64 {
+		fullAt = ddd.FullAt.Format("2006-01-02 15:04")
+	}
 	return []string{
 		ddd.Host,
 		ddd.DestDir,
@@ -478,6 +618,8 @@ func (ddd *destDirData) Strings() []string {
 		DurationString(ddd.AvgPlotTime),
 		fmt.Sprintf("%d", ddd.Count),
 		fmt.Sprintf("%d", ddd.Failed),
+		fmt.Sprintf("%d", ddd.InFlight),
+		fullAt,
 	}
 }
 
@@ -486,10 +628,13 @@ func (client *Client) makeDestDirsData() map[string]*destDirData {
 
 	for host, msg := range client.msg {
 		for destDir, plotSpace := range msg.TargetDirs {
+			forecast := client.forecastsOf(host).dirs[destDir]
 			destDirs[host+"||"+destDir] = &destDirData{
 				Host:           host,
 				DestDir:        destDir,
 				AvailableBytes: plotSpace,
+				InFlight:       len(forecast.Finishes),
+				FullAt:         forecast.FullAt,
 			}
 		}
 
@@ -539,7 +684,25 @@ func (client *Client) drawDestDirsTable() {
 		client.destDirsTable.ClearRowData(key)
 	}
 
-	client.destDirsTable.SetTitle(fmt.Sprintf(" Dest Directories [%d] ", len(destDirs)))
+	title := fmt.Sprintf(" Dest Directories [%d] ", len(destDirs))
+	if at := client.farmFull(time.Now()); !at.IsZero() {
+		title += fmt.Sprintf("- all full at %s ", at.Format("2006-01-02 15:04"))
+	}
+	client.destDirsTable.SetTitle(title)
+}
+
+// farmFull returns when the destination directories of every host are expected to be full.
+func (client *Client) farmFull(now time.Time) time.Time {
+	var forecasts []diskForecast
+	for host := range client.msg {
+		for _, forecast := range client.forecastsOf(host).dirs {
+			forecasts = append(forecasts, forecast)
+		}
+	}
+	if len(forecasts) == 0 {
+		return time.Time{}
+	}
+	return forecastFarmFull(forecasts, now)
 }
 
 // Archived plots
@@ -547,7 +710,7 @@ func (client *Client) drawDestDirsTable() {
 type archivedPlotData struct {
 	Host      string        `header:"Host"`
 	PlotId    string        `header:"Plot Id"`
-	Status    int           `header:"Status"`
+	Status    int           `header:"Status" format:"state"`
 	Phase     string        `header:"Phase" data-align:"right"`
 	StartTime time.Time     `header:"Start Time"`
 	EndTime   time.Time     `header:"End Time"`
@@ -557,19 +720,10 @@ type archivedPlotData struct {
 }
 
 func (apd *archivedPlotData) Strings() []string {
-	status := "Unknown"
-	switch apd.Status {
-	case PlotRunning:
-		status = "Running"
-	case PlotError:
-		status = "Errored"
-	case PlotFinished:
-		status = "Finished"
-	}
 	return []string{
 		apd.Host,
 		shortenPlotId(apd.PlotId),
-		status,
+		stateString(apd.Status),
 		apd.Phase,
 		apd.StartTime.Format("2006-01-02 15:04:05"),
 		apd.EndTime.Format("2006-01-02 15:04:05"),
@@ -607,6 +761,7 @@ func (client *Client) drawArchivedPlotsTable() {
 		for _, plot := range msg.Archived {
 			delete(keysToRemove, plot.Id)
 			client.archivedLogs[plot.Id] = plot.Tail
+			client.plotHosts[plot.Id] = host
 			client.archivedPlotsTable.SetRowData(plot.Id, client.makeArchivedPlotData(host, plot))
 			switch plot.State {
 			case PlotFinished:
@@ -641,26 +796,55 @@ func (client *Client) selectArchivedPlot(key string) {
 	} else {
 		client.logTextbox.SetText("")
 	}
+	client.fullLogPlotId = ""
+	client.followPlotLog(key)
 }
 
 // Host data
 
 type hostsData struct {
-	Host   string `header:"Host"`
-	Status string `header:"Status"`
+	Host     string        `header:"Host"`
+	Address  string        `header:"Address"`
+	Tags     string        `header:"Tags"`
+	Status   string        `header:"Status"`
+	LastSeen time.Time     `header:"Last Seen"`
+	Latency  time.Duration `header:"Latency" data-align:"right"`
 }
 
 func (hd *hostsData) Strings() []string {
+	latency := ""
+	if !hd.LastSeen.IsZero() {
+		latency = fmt.Sprintf("%d ms", hd.Latency/time.Millisecond)
+	}
 	return []string{
 		hd.Host,
+		hd.Address,
+		hd.Tags,
 		hd.Status,
+		AgoString(hd.LastSeen, time.Now()),
+		latency,
 	}
 }
 
 func (client *Client) makeHostsData(host string, msg *Msg) *hostsData {
 	hd := &hostsData{}
 	hd.Host = host
+	if h, ok := client.hosts[host]; ok {
+		hd.Address = h.Address + h.prefix
+		hd.Tags = strings.Join(h.Tags, ",")
+	}
 	hd.Status = msg.Status
+	if status := client.hostStatus[host]; status.Err != nil && !status.NextRetry.IsZero() {
+		hd.Status = fmt.Sprintf("retry in %s: %s", DurationString(time.Until(status.NextRetry).Round(time.Second)), status.Err)
+	}
+	if warning := diskFullStatus(msg, client.forecastsOf(host), time.Now()); len(warning) > 0 {
+		if len(hd.Status) > 0 {
+			hd.Status += " - "
+		}
+		hd.Status += "dest " + warning
+	}
+	hd.LastSeen = client.hostStatus[host].LastSeen
+	hd.Latency = client.hostStatus[host].Latency
 	return hd
 }
 
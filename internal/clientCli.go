@@ -0,0 +1,346 @@
+package internal
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"plotng/internal/widget"
+)
+
+// Output formats of the client commands.
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+)
+
+const commandUsage = `commands:
+  status                     hosts and their status
+  plots ls                   active plots
+  plots kill <id>            kill a plot, by plot id or its prefix
+  dirs [temp|dest]           plot and dest directories
+  archive [--since 24h]      archived plots, optionally only those ended since a duration or date (2006-01-02)`
+
+// ErrUnreachable is returned by RunCommand after printing the output when some hosts could not be queried.
+var ErrUnreachable = errors.New("some hosts are unreachable")
+
+// RunCommand queries the hosts once and prints the output of a command, instead of running the UI.
+func (client *Client) RunCommand(hostList string, args []string, format string, out io.Writer) error {
+	if format != FormatTable && format != FormatJSON && format != FormatCSV {
+		return fmt.Errorf("unknown format %s, expected table, json or csv", format)
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n%s", commandUsage)
+	}
+	client.Discover = false // commands do not wait for announcements
+	flagHosts, fileHosts, err := client.initialHosts(hostList)
+	if err != nil {
+		return err
+	}
+	unreachable := client.fetchHosts(append(flagHosts, fileHosts...))
+
+	switch {
+	case args[0] == "status":
+		var rows []widget.SortableRow
+		for _, host := range client.sortedHostNames() {
+			rows = append(rows, client.makeHostsData(host, client.msg[host]))
+		}
+		err = writeRows(out, format, hostsData{}, rows)
+	case args[0] == "plots" && len(args) == 2 && args[1] == "ls":
+		var rows []widget.SortableRow
+		for _, host := range client.sortedHostNames() {
+			plots := client.msg[host].Actives
+			sort.Slice(plots, func(i, j int) bool { return plots[i].PlotId < plots[j].PlotId })
+			for _, plot := range plots {
+				rows = append(rows, client.makeActivePlotsData(host, plot))
+			}
+		}
+		err = writeRows(out, format, activePlotsData{}, rows)
+	case args[0] == "plots" && len(args) == 3 && args[1] == "kill":
+		err = client.killPlot(args[2], out)
+	case args[0] == "dirs" && len(args) <= 2:
+		err = client.writeDirs(out, format, args[1:])
+	case args[0] == "archive":
+		err = client.writeArchive(out, format, args[1:])
+	default:
+		return fmt.Errorf("unknown command %s\n%s", strings.Join(args, " "), commandUsage)
+	}
+	if err == nil && unreachable {
+		return ErrUnreachable
+	}
+	return err
+}
+
+// fetchHosts gets the Msg of every host, returning true if any could not be queried.
+func (client *Client) fetchHosts(entries []HostEntry) (unreachable bool) {
+	client.hosts = map[string]*clientHost{}
+	client.msg = map[string]*Msg{}
+	client.plotHosts = map[string]string{}
+	client.hostStatus = map[string]hostStatus{}
+
+	type result struct {
+		msg     *Msg
+		latency time.Duration
+		err     error
+	}
+	results := make([]result, len(entries))
+	var wg sync.WaitGroup
+	for i := range entries {
+		entries[i].normalize()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := &results[i]
+			r.msg, r.latency, r.err = fetchMsg(context.Background(), entries[i].Address, entries[i].Token, url.Values{})
+		}(i)
+	}
+	wg.Wait()
+
+	for i, entry := range entries {
+		if _, ok := client.hosts[entry.Name]; ok {
+			continue
+		}
+		client.hosts[entry.Name] = &clientHost{HostEntry: entry}
+		if err := results[i].err; err != nil {
+			unreachable = true
+			client.msg[entry.Name] = &Msg{Status: fmt.Sprintf("unreachable: %s", err)}
+			client.hostStatus[entry.Name] = hostStatus{Err: err}
+			continue
+		}
+		client.hostStatus[entry.Name] = hostStatus{LastSeen: time.Now(), Latency: results[i].latency}
+		if msg := results[i].msg; msg.Hosts != nil {
+			client.updateHub(entry.Name, msg)
+		} else {
+			client.setMsg(entry.Name, msg)
+		}
+	}
+	return unreachable
+}
+
+func (client *Client) sortedHostNames() []string {
+	var names []string
+	for name := range client.msg {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// killPlot kills the active plot with the given id, PlotId or unique prefix of its id.
+func (client *Client) killPlot(id string, out io.Writer) error {
+	id = strings.SplitN(id, "...", 2)[0] // shortened ids, as shown by plots ls
+	var host *clientHost
+	var plot *ActivePlot
+	for name, msg := range client.msg {
+		for _, p := range msg.Actives {
+			if (len(p.Id) > 0 && strings.HasPrefix(p.Id, id)) || fmt.Sprint(p.PlotId) == id {
+				if plot != nil {
+					return fmt.Errorf("plot id %s is ambiguous", id)
+				}
+				host, plot = client.hosts[name], p
+			}
+		}
+	}
+	if plot == nil {
+		return fmt.Errorf("no active plot with id %s", id)
+	}
+	req, err := host.request(context.Background(), "DELETE", fmt.Sprintf("/plots/%d", plot.PlotId))
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to kill plot: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if resp.Header.Get(killedHeader) != fmt.Sprint(plot.PlotId) {
+		return fmt.Errorf("failed to kill plot: server on %s does not support kill", host.Name)
+	}
+	fmt.Fprintf(out, "Killed plot %s on %s\n", plot.Id, host.Name)
+	return nil
+}
+
+func (client *Client) writeDirs(out io.Writer, format string, args []string) error {
+	which := ""
+	if len(args) > 0 {
+		which = args[0]
+		if which != "temp" && which != "dest" {
+			return fmt.Errorf("unknown directory type %s, expected temp or dest", which)
+		}
+	}
+	var plotDirs, destDirs []widget.SortableRow
+	plotData := client.makePlotDirsData()
+	var keys []string
+	for key := range plotData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		plotDirs = append(plotDirs, plotData[key])
+	}
+	destData := client.makeDestDirsData()
+	keys = nil
+	for key := range destData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		destDirs = append(destDirs, destData[key])
+	}
+	switch {
+	case which == "temp":
+		return writeRows(out, format, plotDirData{}, plotDirs)
+	case which == "dest":
+		return writeRows(out, format, destDirData{}, destDirs)
+	case format == FormatJSON:
+		return writeJSONObject(out, map[string]interface{}{
+			"TempDirs": rowRecords(plotDirs),
+			"DestDirs": rowRecords(destDirs),
+		})
+	}
+	if err := writeRows(out, format, plotDirData{}, plotDirs); err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	return writeRows(out, format, destDirData{}, destDirs)
+}
+
+func (client *Client) writeArchive(out io.Writer, format string, args []string) error {
+	flags := flag.NewFlagSet("archive", flag.ContinueOnError)
+	flags.SetOutput(out)
+	since := flags.String("since", "", "only plots ended since a duration (eg. 24h) or a date (2006-01-02)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	var from time.Time
+	if len(*since) > 0 {
+		if d, err := time.ParseDuration(*since); err == nil {
+			from = time.Now().Add(-d)
+		} else if t, err := time.ParseInLocation("2006-01-02", *since, time.Local); err == nil {
+			from = t
+		} else {
+			return fmt.Errorf("invalid --since %s, expected a duration or a date", *since)
+		}
+	}
+	var data []*archivedPlotData
+	for _, host := range client.sortedHostNames() {
+		for _, plot := range client.msg[host].Archived {
+			if apd := client.makeArchivedPlotData(host, plot); !apd.EndTime.Before(from) {
+				data = append(data, apd)
+			}
+		}
+	}
+	sort.SliceStable(data, func(i, j int) bool { return data[i].EndTime.Before(data[j].EndTime) })
+	var rows []widget.SortableRow
+	for _, apd := range data {
+		rows = append(rows, apd)
+	}
+	return writeRows(out, format, archivedPlotData{}, rows)
+}
+
+// writeRows prints rows of the type of the table data, formatted as shown in the UI for the table
+// format, and with raw values keyed by field name for JSON and CSV.
+func writeRows(out io.Writer, format string, dataType interface{}, rows []widget.SortableRow) error {
+	t := reflect.TypeOf(dataType)
+	switch format {
+	case FormatJSON:
+		return writeJSONObject(out, rowRecords(rows))
+	case FormatCSV:
+		w := csv.NewWriter(out)
+		var names []string
+		for i := 0; i < t.NumField(); i++ {
+			names = append(names, t.Field(i).Name)
+		}
+		w.Write(names)
+		for _, row := range rows {
+			var values []string
+			for _, field := range rowFields(row) {
+				values = append(values, fmt.Sprint(field.value))
+			}
+			w.Write(values)
+		}
+		w.Flush()
+		return w.Error()
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	var headers []string
+	for i := 0; i < t.NumField(); i++ {
+		headers = append(headers, t.Field(i).Tag.Get("header"))
+	}
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row.Strings(), "\t"))
+	}
+	return w.Flush()
+}
+
+type rowField struct {
+	name  string
+	value interface{}
+}
+
+// rowFields returns the raw values of a row: times in RFC 3339, durations in seconds and plot states by name.
+func rowFields(row widget.SortableRow) []rowField {
+	v := reflect.ValueOf(row).Elem()
+	var fields []rowField
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		var value interface{}
+		switch f := v.Field(i).Interface().(type) {
+		case time.Time:
+			if !f.IsZero() {
+				value = f.Format(time.RFC3339)
+			} else {
+				value = ""
+			}
+		case time.Duration:
+			value = int64(f / time.Second)
+		case int:
+			if field.Tag.Get("format") == "state" {
+				value = stateString(f)
+			} else {
+				value = f
+			}
+		default:
+			value = f
+		}
+		fields = append(fields, rowField{name: field.Name, value: value})
+	}
+	return fields
+}
+
+func rowRecords(rows []widget.SortableRow) []map[string]interface{} {
+	records := []map[string]interface{}{}
+	for _, row := range rows {
+		record := map[string]interface{}{}
+		for _, field := range rowFields(row) {
+			record[field.name] = field.value
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func writeJSONObject(out io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunCommand(t *testing.T) {
+	now := time.Now()
+	svr := &Server{
+		config: &PlotConfig{CurrentConfig: &Config{AuthToken: "secret"}},
+		active: map[int64]*ActivePlot{
+			1: {PlotId: 1, Id: "0123456789abcdef0123456789abcdef", State: PlotRunning, Phase: "2/4", PlotDir: "/tmp1", TargetDir: "/dest1", StartTime: now.Add(-time.Hour)},
+		},
+		archive: []*ActivePlot{
+			{PlotId: 2, Id: "old", State: PlotFinished, StartTime: now.Add(-72 * time.Hour), EndTime: now.Add(-60 * time.Hour)},
+			{PlotId: 3, Id: "new", State: PlotError, StartTime: now.Add(-3 * time.Hour), EndTime: now.Add(-2 * time.Hour)},
+		},
+	}
+	stub := httptest.NewServer(svr)
+	defer stub.Close()
+	hostsFile := writeHostsFile(t, []HostEntry{{Name: "plotter1", Address: strings.TrimPrefix(stub.URL, "http://"), Token: "secret"}})
+
+	run := func(format string, args ...string) (string, error) {
+		t.Helper()
+		var out bytes.Buffer
+		client := &Client{HostsFile: hostsFile}
+		err := client.RunCommand("", args, format, &out)
+		return out.String(), err
+	}
+
+	out, err := run(FormatTable, "plots", "ls")
+	if err != nil || !strings.Contains(out, "Plot ID") || !strings.Contains(out, "0123456789...") {
+		t.Errorf("unexpected plots ls: %v\n%s", err, out)
+	}
+
+	out, err = run(FormatJSON, "archive", "--since", "24h")
+	var records []map[string]interface{}
+	if err != nil || json.Unmarshal([]byte(out), &records) != nil || len(records) != 1 || records[0]["PlotId"] != "new" || records[0]["Status"] != "Errored" {
+		t.Errorf("unexpected archive: %v\n%s", err, out)
+	}
+
+	out, err = run(FormatCSV, "dirs", "temp")
+	rows, _ := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil || len(rows) != 2 || rows[0][1] != "PlotDir" {
+		t.Errorf("unexpected dirs: %v\n%s", err, out)
+	}
+
+	if out, err = run(FormatTable, "plots", "kill", "0123456789..."); err != nil || !strings.Contains(out, "Killed plot") {
+		t.Errorf("unexpected kill: %v\n%s", err, out)
+	}
+	if _, err = run(FormatTable, "plots", "kill", "missing"); err == nil {
+		t.Error("killing an unknown plot should fail")
+	}
+
+	stub.Close()
+	if out, err = run(FormatTable, "status"); err != ErrUnreachable || !strings.Contains(out, "unreachable") {
+		t.Errorf("unexpected status of an unreachable host: %v\n%s", err, out)
+	}
+}
+
+func writeHostsFile(t *testing.T, hosts []HostEntry) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "plotng-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	hf := &HostsFile{Path: filepath.Join(dir, "hosts.json")}
+	if err := hf.Save(hosts); err != nil {
+		t.Fatal(err)
+	}
+	return hf.Path
+}
+
+func TestKillPlotOldServer(t *testing.T) {
+	// servers older than DELETE /plots/{id} answer any DELETE without killing anything
+	old := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {}))
+	defer old.Close()
+	client := &Client{
+		hosts: map[string]*clientHost{"old": {HostEntry: HostEntry{Name: "old", Address: strings.TrimPrefix(old.URL, "http://")}}},
+		msg:   map[string]*Msg{"old": {Actives: []*ActivePlot{{PlotId: 1, Id: "abc"}}}},
+	}
+	var out strings.Builder
+	if err := client.killPlot("abc", &out); err == nil || !strings.Contains(err.Error(), "does not support kill") {
+		t.Errorf("expected the kill to fail: %v\n%s", err, out.String())
+	}
+}
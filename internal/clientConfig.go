@@ -0,0 +1,327 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"plotng/internal/widget"
+)
+
+// Panels of the client, as named in the client config file.
+const (
+	panelActive    = "active"
+	panelPlotDirs  = "plotDirs"
+	panelDestDirs  = "destDirs"
+	panelArchived  = "archived"
+	panelHosts     = "hosts"
+	panelLog       = "log"
+	panelScheduler = "scheduler"
+)
+
+const mainPage = "main"
+
+var panelNames = []string{panelActive, panelPlotDirs, panelDestDirs, panelArchived, panelHosts, panelLog, panelScheduler}
+
+// ClientConfig is the layout of the client: the rows of panels from top to bottom, the columns shown in
+// each table and the panel shown full screen, if any.
+type ClientConfig struct {
+	Rows       []PanelRow
+	Columns    map[string][]string // headers of the columns shown by panel, all of them if missing
+	FullScreen string
+}
+
+// PanelRow is a row of panels shown side by side.
+type PanelRow struct {
+	Panels []string
+	Size   int // height in lines, 0 to share the remaining height by Weight (a row starting with the hosts panel fits the hosts)
+	Weight int // share of the remaining height, default 1
+}
+
+func defaultClientConfig() *ClientConfig {
+	return &ClientConfig{
+		Rows: []PanelRow{
+			{Panels: []string{panelActive}},
+			{Panels: []string{panelPlotDirs, panelDestDirs}},
+			{Panels: []string{panelArchived}},
+			{Panels: []string{panelHosts, panelScheduler}},
+			{Panels: []string{panelLog}},
+		},
+		Columns: map[string][]string{},
+	}
+}
+
+// DefaultClientConfigPath returns the client config file in the user's configuration directory.
+func DefaultClientConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "plotng", "client.json")
+}
+
+// loadClientConfig reads the client config file, using the default layout if it does not exist.
+func loadClientConfig(path string) (*ClientConfig, error) {
+	config := defaultClientConfig()
+	if len(path) == 0 {
+		return config, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return nil, err
+	}
+	config.Rows = nil
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid client config [%s]: %w", path, err)
+	}
+	if len(config.Rows) == 0 {
+		config.Rows = defaultClientConfig().Rows
+	}
+	if config.Columns == nil {
+		config.Columns = map[string][]string{}
+	}
+	seen := map[string]bool{}
+	for _, row := range config.Rows {
+		for _, name := range row.Panels {
+			if !isPanelName(name) {
+				return nil, fmt.Errorf("invalid client config [%s]: unknown panel %s, expected one of %v", path, name, panelNames)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("invalid client config [%s]: panel %s is shown twice", path, name)
+			}
+			seen[name] = true
+		}
+	}
+	if len(config.FullScreen) > 0 && !seen[config.FullScreen] {
+		config.FullScreen = ""
+	}
+	return config, nil
+}
+
+func isPanelName(name string) bool {
+	for _, panel := range panelNames {
+		if name == panel {
+			return true
+		}
+	}
+	return false
+}
+
+func saveClientConfig(path string, config *ClientConfig) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+func (client *Client) saveConfig() {
+	if len(client.ConfigFile) == 0 {
+		return
+	}
+	if err := saveClientConfig(client.ConfigFile, client.config); err != nil {
+		client.logTextbox.SetText(fmt.Sprintf("Failed to save client config [%s]: %s", client.ConfigFile, err))
+	}
+}
+
+func (client *Client) panel(name string) tview.Primitive {
+	switch name {
+	case panelActive:
+		return client.activePlotsTable
+	case panelPlotDirs:
+		return client.plotDirsTable
+	case panelDestDirs:
+		return client.destDirsTable
+	case panelArchived:
+		return client.archivedPlotsTable
+	case panelHosts:
+		return client.hostsTable
+	case panelLog:
+		return client.logTextbox
+	case panelScheduler:
+		return client.schedulerView
+	}
+	return nil
+}
+
+func (client *Client) panelTable(name string) *widget.SortedTable {
+	if table, ok := client.panel(name).(*widget.SortedTable); ok {
+		return table
+	}
+	return nil
+}
+
+// layoutPanels arranges the panels of the config, and shows the visible columns of each table.
+func (client *Client) layoutPanels(hostCount int) {
+	for _, name := range panelNames {
+		if table := client.panelTable(name); table != nil {
+			table.SetVisibleColumns(client.config.Columns[name])
+		}
+	}
+	client.panelOrder = nil
+	mainPanel := tview.NewFlex()
+	mainPanel.SetDirection(tview.FlexRow)
+	for _, row := range client.config.Rows {
+		if len(row.Panels) == 0 {
+			continue
+		}
+		var item tview.Primitive
+		if len(row.Panels) == 1 {
+			item = client.panel(row.Panels[0])
+		} else {
+			flex := tview.NewFlex()
+			flex.SetDirection(tview.FlexColumn)
+			for _, name := range row.Panels {
+				flex.AddItem(client.panel(name), 0, 1, false)
+			}
+			item = flex
+		}
+		client.panelOrder = append(client.panelOrder, row.Panels...)
+		size, weight := row.Size, row.Weight
+		if size == 0 && row.Panels[0] == panelHosts {
+			size = hostsPanelSize(hostCount)
+		} else if weight <= 0 {
+			weight = 1
+		}
+		mainPanel.AddItem(item, size, weight, false)
+	}
+	client.mainPanel = mainPanel
+	if len(client.panelOrder) == 0 {
+		client.panelOrder = []string{panelActive}
+		mainPanel.AddItem(client.activePlotsTable, 0, 1, false)
+	}
+
+	if len(client.config.FullScreen) > 0 {
+		client.pages.AddPage(mainPage, client.panel(client.config.FullScreen), true, true)
+		client.focusPanel(client.config.FullScreen)
+	} else {
+		client.pages.AddPage(mainPage, client.mainPanel, true, true)
+		client.focusPanel(client.panelOrder[0])
+	}
+}
+
+// hostsPanelSize fits the hosts table to the number of hosts, up to 4 as it is low priority.
+func hostsPanelSize(hostCount int) int {
+	if hostCount > 4 {
+		hostCount = 4
+	} else if hostCount < 1 {
+		hostCount = 1
+	}
+	const extraRows = 1 + 1 + 1 // border + header row + border
+	return hostCount + extraRows
+}
+
+func (client *Client) isPanelShown(name string) bool {
+	for _, panel := range client.panelOrder {
+		if panel == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (client *Client) focusPanel(name string) {
+	if table := client.panelTable(name); table != nil {
+		table.SetFocus(client.app)
+	} else if p := client.panel(name); p != nil {
+		client.app.SetFocus(p)
+	}
+}
+
+func (client *Client) focusedPanel() string {
+	for _, name := range client.panelOrder {
+		if p := client.panel(name); p != nil && p.HasFocus() {
+			return name
+		}
+	}
+	return ""
+}
+
+// panelKeys handles the keys shared by the panels: f toggles full screen, c chooses the columns of a
+// table and tab moves to the next panel.
+func (client *Client) panelKeys(event *tcell.EventKey) *tcell.EventKey {
+	switch {
+	case event.Rune() == 'f':
+		client.toggleFullScreen()
+		return nil
+	case event.Rune() == 'c' && client.panelTable(client.focusedPanel()) != nil:
+		client.showColumnsForm(client.focusedPanel())
+		return nil
+	case event.Key() == tcell.KeyTab:
+		if len(client.config.FullScreen) > 0 {
+			return nil
+		}
+		current := client.focusedPanel()
+		for i, name := range client.panelOrder {
+			if name == current {
+				client.focusPanel(client.panelOrder[(i+1)%len(client.panelOrder)])
+				return nil
+			}
+		}
+	}
+	return event
+}
+
+func (client *Client) toggleFullScreen() {
+	name := client.focusedPanel()
+	if len(name) == 0 {
+		return
+	}
+	if len(client.config.FullScreen) > 0 {
+		client.config.FullScreen = ""
+		client.pages.AddPage(mainPage, client.mainPanel, true, true)
+	} else {
+		client.config.FullScreen = name
+		client.pages.AddPage(mainPage, client.panel(name), true, true)
+	}
+	client.focusPanel(name)
+	client.saveConfig()
+}
+
+func (client *Client) showColumnsForm(name string) {
+	const pageName = "columns"
+	table := client.panelTable(name)
+	visible := map[string]bool{}
+	for _, header := range table.VisibleColumns() {
+		visible[header] = true
+	}
+	form := tview.NewForm()
+	for _, header := range table.Headers() {
+		form.AddCheckbox(header, visible[header], nil)
+	}
+	form.AddButton("Save", func() {
+		var columns []string
+		for _, header := range table.Headers() {
+			if form.GetFormItemByLabel(header).(*tview.Checkbox).IsChecked() {
+				columns = append(columns, header)
+			}
+		}
+		if len(columns) == len(table.Headers()) || len(columns) == 0 {
+			delete(client.config.Columns, name)
+			columns = nil
+		} else {
+			client.config.Columns[name] = columns
+		}
+		table.SetVisibleColumns(columns)
+		client.closeModal(pageName)
+		client.saveConfig()
+	})
+	form.AddButton("Cancel", func() {
+		client.closeModal(pageName)
+	})
+	form.SetCancelFunc(func() {
+		client.closeModal(pageName)
+	})
+	form.SetBorder(true).SetTitle(" Columns ").SetTitleAlign(tview.AlignLeft)
+	client.showModal(pageName, form, 40, len(table.Headers())*2+5)
+}
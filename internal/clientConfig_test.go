@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadClientConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plotng", "client.json")
+
+	config, err := loadClientConfig(path)
+	if err != nil {
+		t.Fatalf("missing file: %s", err)
+	}
+	if len(config.Rows) != 5 || len(config.Rows[1].Panels) != 2 {
+		t.Errorf("expected the default layout, got %+v", config.Rows)
+	}
+
+	config.Rows = []PanelRow{{Panels: []string{panelActive}, Weight: 2}, {Panels: []string{panelLog}, Size: 10}}
+	config.Columns[panelActive] = []string{"Host", "Plot ID"}
+	config.FullScreen = panelLog
+	if err := saveClientConfig(path, config); err != nil {
+		t.Fatalf("failed to save: %s", err)
+	}
+	loaded, err := loadClientConfig(path)
+	if err != nil {
+		t.Fatalf("failed to load: %s", err)
+	}
+	if len(loaded.Rows) != 2 || loaded.Rows[0].Weight != 2 || loaded.Rows[1].Size != 10 || loaded.FullScreen != panelLog ||
+		strings.Join(loaded.Columns[panelActive], ",") != "Host,Plot ID" {
+		t.Errorf("unexpected config %+v", loaded)
+	}
+
+	for _, invalid := range []string{
+		`{"Rows": [{"Panels": ["active", "graphs"]}]}`,
+		`{"Rows": [{"Panels": ["active"]}, {"Panels": ["active"]}]}`,
+		`{"Rows": `,
+	} {
+		if err := ioutil.WriteFile(path, []byte(invalid), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadClientConfig(path); err == nil {
+			t.Errorf("expected an error for %s", invalid)
+		}
+	}
+}
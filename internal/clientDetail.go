@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const detailPage = "detail"
+
+var phaseNames = [phaseCount]string{"Phase 1", "Phase 2", "Phase 3", "Phase 4", "Copy"}
+
+// activeTableKeys handles the key showing the details (Enter) of the selected plot in the Active Plots table.
+func (client *Client) activeTableKeys(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyEnter {
+		client.showPlotDetail(client.activePlotsTable.GetSelection())
+		return nil
+	}
+	return client.tableKeys(event)
+}
+
+func (client *Client) showPlotDetail(id string) {
+	if _, plot := client.findPlot(id); plot == nil {
+		return
+	}
+	client.detailPlotId = id
+	if client.detailView == nil {
+		client.detailView = tview.NewTextView()
+		client.detailView.SetBorder(true)
+		client.detailView.SetTitleAlign(tview.AlignLeft)
+		client.detailView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Key() == tcell.KeyEnter || event.Key() == tcell.KeyEscape {
+				client.closeModal(detailPage)
+				return nil
+			}
+			return event
+		})
+	}
+	client.showModal(detailPage, client.detailView, 120, 40)
+	client.drawPlotDetail()
+}
+
+// drawPlotDetail updates the plot details if they are shown.
+func (client *Client) drawPlotDetail() {
+	if client.detailView == nil || !client.pages.HasPage(detailPage) {
+		return
+	}
+	host, plot := client.findPlot(client.detailPlotId)
+	if plot == nil {
+		client.detailView.SetText("The plot is no longer available")
+		return
+	}
+	client.detailView.SetTitle(fmt.Sprintf(" Plot %s (Enter or Esc to close) ", shortenPlotId(plot.Id)))
+	client.detailView.SetText(plotDetailText(host, plot, time.Now()))
+}
+
+// findPlot returns the active or archived plot with the given id.
+func (client *Client) findPlot(id string) (string, *ActivePlot) {
+	if len(id) == 0 {
+		return "", nil
+	}
+	for host, msg := range client.msg {
+		for _, plot := range msg.Actives {
+			if plot.Id == id {
+				return host, plot
+			}
+		}
+		for _, plot := range msg.Archived {
+			if plot.Id == id {
+				return host, plot
+			}
+		}
+	}
+	return "", nil
+}
+
+// maskKey shortens a key to its first and last characters, enough to tell keys apart.
+func maskKey(key string) string {
+	if len(key) <= 12 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:6] + "..." + key[len(key)-4:]
+}
+
+// plotDetailText describes every field of a plot, with its keys masked.
+func plotDetailText(host string, plot *ActivePlot, now time.Time) string {
+	timeString := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02 15:04:05")
+	}
+	keys := []string{plot.Fingerprint, plot.FarmerPublicKey, plot.PoolPublicKey, plot.ContractAddress}
+	commandLine := plot.CommandLine
+	for _, key := range keys {
+		if len(key) > 0 {
+			commandLine = strings.ReplaceAll(commandLine, key, maskKey(key))
+		}
+	}
+	size := "k32"
+	if plot.PlotSize > 0 {
+		size = fmt.Sprintf("k%d", plot.PlotSize)
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	field := func(name string, value interface{}) {
+		fmt.Fprintf(w, "%s\t%v\n", name, value)
+	}
+	field("Host", host)
+	field("Plot ID", plot.Id)
+	field("Plot Number", plot.PlotId)
+	field("PID", plot.Pid)
+	field("Status", fmt.Sprintf("%s %s %s", stateString(plot.State), plot.Phase, plot.Progress))
+	field("Command Line", commandLine)
+	field("Fingerprint", maskKey(plot.Fingerprint))
+	field("Farmer Key", maskKey(plot.FarmerPublicKey))
+	field("Pool Key", maskKey(plot.PoolPublicKey))
+	field("Contract Address", maskKey(plot.ContractAddress))
+	field("Plot Size", size)
+	field("Threads", plot.Threads)
+	field("Buffers", plot.Buffers)
+	field("Buckets", plot.BucketSize)
+	field("Bitfield Disabled", plot.DisableBitField)
+	field("Temp Dir", plot.PlotDir)
+	field("Tmp2 Dir", plot.Tmp2Dir)
+	field("Target Dir", plot.TargetDir)
+	field("Target as Tmp2", plot.UseTargetForTmp2)
+	field("Plot Log Dir", plot.SavePlotLogDir)
+	field("CPU Set", plot.CPUSet)
+	field("NUMA Node", plot.NumaNode)
+	field("Last Log", timeString(plot.LastLogTime))
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "Phase\tStart\tEnd\tDuration")
+	for phase := 1; phase <= phaseCount; phase++ {
+		start, end := plot.getPhaseTime(phase-1), plot.getPhaseTime(phase)
+		duration := ""
+		if !start.IsZero() && !end.IsZero() {
+			duration = DurationString(end.Sub(start))
+		} else if !start.IsZero() && phase == plot.getCurrentPhase() && plot.State == PlotRunning {
+			duration = DurationString(now.Sub(start)) + " so far"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", phaseNames[phase-1], timeString(start), timeString(end), duration)
+	}
+	total := ""
+	if !plot.EndTime.IsZero() {
+		total = DurationString(plot.EndTime.Sub(plot.StartTime))
+	}
+	fmt.Fprintf(w, "Total\t%s\t%s\t%s\n", timeString(plot.StartTime), timeString(plot.EndTime), total)
+	fmt.Fprintln(w)
+
+	if len(plot.TableTimings) > 0 {
+		fmt.Fprintln(w, "Tables\tDuration")
+		for _, timing := range plot.TableTimings {
+			fmt.Fprintf(w, "%s\t%s\n", timing.Name, DurationString(time.Duration(timing.Seconds*float64(time.Second))))
+		}
+		fmt.Fprintln(w)
+	}
+
+	finalSize := ""
+	if plot.FinalSize > 0 {
+		finalSize = fmt.Sprintf("%s (%d bytes)", SpaceString(plot.FinalSize), plot.FinalSize)
+	}
+	field("Final File", plot.FinalPath)
+	field("Final Size", finalSize)
+	w.Flush()
+	return buf.String()
+}
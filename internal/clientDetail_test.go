@@ -0,0 +1,33 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlotDetailText(t *testing.T) {
+	farmerKey := "8a3d0b7e9f1c2d3e4f5a6b7c8d9e0f1a"
+	plot := &ActivePlot{
+		Id:              "abcdef0123456789abcdef0123456789",
+		State:           PlotFinished,
+		FarmerPublicKey: farmerKey,
+		CommandLine:     "chia plots create -f " + farmerKey,
+		StartTime:       initialTime,
+		Phase1Time:      initialTime.Add(time.Hour),
+		EndTime:         initialTime.Add(5 * time.Hour),
+		TableTimings:    []TableTiming{{"Phase 1 table 1", 90}},
+		FinalPath:       "/dest/plot.plot",
+		FinalSize:       108 * GB,
+	}
+	text := plotDetailText("plotter1", plot, initialTime.Add(6*time.Hour))
+	if strings.Contains(text, farmerKey) {
+		t.Errorf("the farmer key is not masked:\n%s", text)
+	}
+	for _, expected := range []string{"chia plots create -f 8a3d0b...0f1a", "Phase 1  ", "01:00:00", "Total", "05:00:00",
+		"Phase 1 table 1  00:01:30", "/dest/plot.plot", "108 GiB"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("expected %q in:\n%s", expected, text)
+		}
+	}
+}
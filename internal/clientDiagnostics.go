@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const diagnosticsPage = "diagnostics"
+
+// getDiagnosis retrieves why a host can or cannot start plots with each temp and target directory.
+func getDiagnosis(host *clientHost) (*Diagnosis, error) {
+	req, err := host.request(context.Background(), "GET", "/api/diagnostics")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// servers without the diagnostics API answer every GET with a Msg
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		return nil, fmt.Errorf("diagnostics not available: %s", resp.Status)
+	}
+	var diagnosis Diagnosis
+	err = json.NewDecoder(resp.Body).Decode(&diagnosis)
+	return &diagnosis, err
+}
+
+// showDiagnostics shows the scheduler rules blocking each temp and target directory pair of a host.
+func (client *Client) showDiagnostics(name string) {
+	host, ok := client.hosts[name]
+	if !ok {
+		return
+	}
+	view := tview.NewTextView()
+	view.SetBorder(true).SetTitleAlign(tview.AlignLeft)
+	view.SetTitle(fmt.Sprintf(" Why not plotting on %s (Enter or Esc to close) ", name))
+	view.SetText("Loading...")
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEnter || event.Key() == tcell.KeyEscape {
+			client.closeModal(diagnosticsPage)
+			return nil
+		}
+		return event
+	})
+	client.showModal(diagnosticsPage, view, 120, 30)
+	go func() {
+		diagnosis, err := getDiagnosis(host)
+		client.app.QueueUpdateDraw(func() {
+			if err != nil {
+				view.SetText(err.Error())
+			} else {
+				view.SetText(diagnosisText(diagnosis))
+			}
+		})
+	}()
+}
+
+// diagnosisText shows the pairs as a matrix of temp directories by target directory, each cell being ok
+// or the rules blocking the pair, followed by the reason of each rule.
+func diagnosisText(d *Diagnosis) string {
+	blocked := map[string][]string{}
+	for _, pair := range d.Pairs {
+		blocked[pair.TempDir+"\x00"+pair.TargetDir] = pair.Blocked
+	}
+
+	var buf bytes.Buffer
+	if len(d.NextTemp) > 0 {
+		fmt.Fprintf(&buf, "Next pair tried: [%s] for [%s]\n\n", d.NextTemp, d.NextTarget)
+	}
+	if len(d.Pairs) > 0 {
+		w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Temp \\ Target\t%s\n", strings.Join(d.TargetDirs, "\t"))
+		for _, temp := range d.TempDirs {
+			cells := []string{temp}
+			for _, target := range d.TargetDirs {
+				rules, ok := blocked[temp+"\x00"+target]
+				switch {
+				case !ok:
+					cells = append(cells, "")
+				case len(rules) == 0:
+					cells = append(cells, "ok")
+				default:
+					cells = append(cells, strings.Join(rules, ","))
+				}
+			}
+			fmt.Fprintln(w, strings.Join(cells, "\t"))
+		}
+		w.Flush()
+		buf.WriteString("\n")
+	}
+	if len(d.Rules) == 0 {
+		buf.WriteString("Nothing blocks new plots\n")
+		return buf.String()
+	}
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	for _, rule := range d.Rules {
+		dir := rule.Dir
+		if len(dir) == 0 {
+			dir = "all"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", rule.Rule, dir, rule.Reason)
+	}
+	w.Flush()
+	return buf.String()
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// archivedTableKeys handles the keys exporting (e) the archived plots and showing the details (Enter)
+// of the selected plot in the Archived Plots table.
+func (client *Client) archivedTableKeys(event *tcell.EventKey) *tcell.EventKey {
+	if event.Rune() == 'e' {
+		client.showExportForm()
+		return nil
+	}
+	if event.Key() == tcell.KeyEnter {
+		client.showPlotDetail(client.archivedPlotsTable.GetSelection())
+		return nil
+	}
+	return client.tableKeys(event)
+}
+
+func (client *Client) showExportForm() {
+	const pageName = "export"
+	formats := []string{FormatCSV, FormatJSON}
+	form := tview.NewForm()
+	form.AddDropDown("Format", formats, 0, nil)
+	form.AddInputField("From", "", 25, nil, nil)
+	form.AddInputField("To", "", 25, nil, nil)
+	form.AddInputField("Directory", "", 40, nil, nil)
+	form.AddInputField("File", "plotng-archive-"+time.Now().Format("20060102")+".csv", 40, nil, nil)
+	form.GetFormItemByLabel("Format").(*tview.DropDown).SetSelectedFunc(func(format string, _ int) {
+		file := form.GetFormItemByLabel("File").(*tview.InputField)
+		name := file.GetText()
+		if ext := filepath.Ext(name); ext == ".csv" || ext == ".json" {
+			file.SetText(strings.TrimSuffix(name, ext) + "." + format)
+		}
+	})
+	form.AddButton("Export", func() {
+		_, format := form.GetFormItemByLabel("Format").(*tview.DropDown).GetCurrentOption()
+		text := func(label string) string {
+			return form.GetFormItemByLabel(label).(*tview.InputField).GetText()
+		}
+		var filter archiveFilter
+		var err error
+		if filter.From, err = parseExportDate(text("From")); err != nil {
+			client.logTextbox.SetText(fmt.Sprintf("Invalid From date, expected 2006-01-02: %s", err))
+			return
+		}
+		if filter.To, err = parseExportDate(text("To")); err != nil {
+			client.logTextbox.SetText(fmt.Sprintf("Invalid To date, expected 2006-01-02: %s", err))
+			return
+		}
+		filter.Dir = text("Directory")
+		client.closeModal(pageName)
+		client.exportArchive(text("File"), format, filter)
+	})
+	form.AddButton("Cancel", func() {
+		client.closeModal(pageName)
+	})
+	form.SetCancelFunc(func() {
+		client.closeModal(pageName)
+	})
+	form.SetBorder(true).SetTitle(" Export Archived Plots (dates as 2006-01-02) ").SetTitleAlign(tview.AlignLeft)
+	client.showModal(pageName, form, 64, 15)
+}
+
+// exportArchive writes the archived plots of every host matching the filter to a file.
+func (client *Client) exportArchive(path string, format string, filter archiveFilter) {
+	archives := map[string][]*ActivePlot{}
+	for host, msg := range client.msg {
+		archives[host] = msg.Archived
+	}
+	records := exportArchive(archives, filter)
+	f, err := os.Create(path)
+	if err == nil {
+		err = writeArchiveRecords(f, format, records)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		client.logTextbox.SetText(fmt.Sprintf("Failed to export archived plots to [%s]: %s", path, err))
+	} else {
+		client.logTextbox.SetText(fmt.Sprintf("Exported %d archived plots to [%s]", len(records), path))
+	}
+}
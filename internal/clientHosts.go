@@ -0,0 +1,278 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Where a host queried by the client comes from.
+const (
+	hostSourceFlag       = "flag"
+	hostSourceFile       = "file"
+	hostSourceDiscovered = "discovered"
+	hostSourceManual     = "manual"
+	hostSourceHub        = "hub" // plotter polled by a plotng-hub
+)
+
+const hostsFileCheckInterval = 10 * time.Second
+
+// hostsRefreshInterval is how often the Hosts table is redrawn, so that "last seen" and "retry in" keep
+// counting between the updates of the hosts.
+const hostsRefreshInterval = time.Second
+
+// clientHost is a plotter queried by the client.
+type clientHost struct {
+	HostEntry
+	source    string
+	cancel    context.CancelFunc
+	hub       string         // name of the plotng-hub the plotter is reached through
+	prefix    string         // path prefix of the plotter on the hub
+	forecasts *hostForecasts // forecasts of the latest Msg of the host
+}
+
+// request creates a request to the plotter, through its hub if it has one.
+func (host *clientHost) request(ctx context.Context, method string, path string) (*http.Request, error) {
+	return newHostRequest(ctx, method, host.Address, host.Token, host.prefix+path)
+}
+
+// addHost starts querying a host, unless a host with the same name or address already exists.
+// Must be called on the tview thread once the application is running.
+func (client *Client) addHost(entry HostEntry, source string) bool {
+	entry.normalize()
+	for _, host := range client.hosts {
+		if host.Name == entry.Name || host.Address == entry.Address {
+			return false
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	host := &clientHost{HostEntry: entry, source: source, cancel: cancel}
+	client.hosts[entry.Name] = host
+
+	watcher := &hostWatcher{
+		host:  entry.Address,
+		token: entry.Token,
+	}
+	watcher.update = func(msg *Msg, status hostStatus) {
+		client.app.QueueUpdateDraw(func() {
+			if ctx.Err() == nil {
+				client.updateHost(entry.Name, msg, status)
+			}
+		})
+	}
+	go watcher.run(ctx)
+	return true
+}
+
+// removeHost stops querying a host and removes its plots from the tables.  Must be called on the tview thread.
+func (client *Client) removeHost(name string) {
+	host, ok := client.hosts[name]
+	if !ok {
+		return
+	}
+	if host.cancel != nil {
+		host.cancel()
+	}
+	delete(client.hosts, name)
+	delete(client.msg, name)
+	delete(client.hostStatus, name)
+	for child, h := range client.hosts {
+		if h.hub == name {
+			delete(client.hosts, child)
+			delete(client.msg, child)
+			delete(client.hostStatus, child)
+		}
+	}
+	client.drawTables()
+}
+
+// updateHub shows every plotter polled by a plotng-hub as a host named hub/plotter.  Must be called
+// on the tview thread.
+func (client *Client) updateHub(name string, msg *Msg) {
+	hub, ok := client.hosts[name]
+	if !ok {
+		return
+	}
+	client.msg[name] = &Msg{Status: msg.Status}
+	for plotter, plotterMsg := range msg.Hosts {
+		child := name + "/" + plotter
+		if _, ok := client.hosts[child]; !ok {
+			client.hosts[child] = &clientHost{
+				HostEntry: HostEntry{Name: child, Address: hub.Address, Tags: hub.Tags, Token: hub.Token},
+				source:    hostSourceHub,
+				hub:       name,
+				prefix:    "/hosts/" + url.PathEscape(plotter),
+			}
+		}
+		client.setMsg(child, plotterMsg)
+		client.hostStatus[child] = hostStatus{LastSeen: plotterMsg.LastSeen, Latency: plotterMsg.Latency}
+	}
+	for child, h := range client.hosts {
+		if _, ok := msg.Hosts[strings.TrimPrefix(child, name+"/")]; h.hub == name && !ok {
+			delete(client.hosts, child)
+			delete(client.msg, child)
+			delete(client.hostStatus, child)
+		}
+	}
+}
+
+// hostEntries returns the hosts from the given source, sorted by name.
+func (client *Client) hostEntries(source string) []HostEntry {
+	var entries []HostEntry
+	for _, host := range client.hosts {
+		if host.source == source {
+			entries = append(entries, host.HostEntry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// refreshHostsTable redraws the Hosts table every hostsRefreshInterval.
+func (client *Client) refreshHostsTable() {
+	ticker := time.NewTicker(hostsRefreshInterval)
+	for range ticker.C {
+		client.app.QueueUpdateDraw(client.drawHostsTable)
+	}
+}
+
+// watchHostsFile adds and removes hosts as the hosts file changes.
+func (client *Client) watchHostsFile() {
+	ticker := time.NewTicker(hostsFileCheckInterval)
+	for range ticker.C {
+		entries, changed, err := client.hostsFile.Load()
+		if err != nil || !changed {
+			continue
+		}
+		client.app.QueueUpdateDraw(func() {
+			client.syncHostsFile(entries)
+		})
+	}
+}
+
+// syncHostsFile makes the hosts from the hosts file match entries.  Must be called on the tview thread.
+func (client *Client) syncHostsFile(entries []HostEntry) {
+	wanted := map[string]HostEntry{}
+	for _, entry := range entries {
+		wanted[entry.Name] = entry
+	}
+	for name, host := range client.hosts {
+		if host.source != hostSourceFile {
+			continue
+		}
+		entry, ok := wanted[name]
+		if !ok || entry.Address != host.Address || entry.Token != host.Token {
+			client.removeHost(name)
+		} else {
+			host.Tags = entry.Tags
+		}
+	}
+	for _, entry := range entries {
+		client.addHost(entry, hostSourceFile)
+	}
+	client.drawHostsTable()
+}
+
+// saveHostsFile writes the hosts added from the hosts file or the UI back to the hosts file.
+func (client *Client) saveHostsFile() {
+	if client.hostsFile == nil {
+		return
+	}
+	if err := client.hostsFile.Save(client.hostEntries(hostSourceFile)); err != nil {
+		client.logTextbox.SetText(fmt.Sprintf("Failed to save hosts file [%s]: %s", client.hostsFile.Path, err))
+	}
+}
+
+// discoverHosts adds the plotters announcing themselves on the LAN.
+func (client *Client) discoverHosts() {
+	err := listenForAnnouncements(context.Background(), func(entry HostEntry) {
+		client.app.QueueUpdateDraw(func() {
+			entry.normalize()
+			if _, ok := client.hosts[entry.Name]; ok {
+				// another plotter with the same name, use its address instead
+				entry.Name = entry.Address
+			}
+			if client.addHost(entry, hostSourceDiscovered) {
+				client.drawHostsTable()
+			}
+		})
+	})
+	if err != nil {
+		client.app.QueueUpdateDraw(func() {
+			client.logTextbox.SetText(fmt.Sprintf("Host discovery failed: %s", err))
+		})
+	}
+}
+
+// hostsTableKeys handles the keys adding (a) and removing (d) hosts, and explaining why a host is not
+// plotting (w), in the Hosts table.
+func (client *Client) hostsTableKeys(event *tcell.EventKey) *tcell.EventKey {
+	switch {
+	case event.Rune() == 'a':
+		client.showAddHostForm()
+		return nil
+	case event.Rune() == 'd' || event.Key() == tcell.KeyDelete:
+		name := client.hostsTable.GetSelection()
+		if host, ok := client.hosts[name]; ok && host.source != hostSourceHub {
+			client.removeHost(name)
+			if host.source == hostSourceFile {
+				client.saveHostsFile()
+			}
+		}
+		return nil
+	case event.Rune() == 'w':
+		client.showDiagnostics(client.hostsTable.GetSelection())
+		return nil
+	}
+	return client.tableKeys(event)
+}
+
+func (client *Client) showAddHostForm() {
+	const pageName = "addHost"
+	form := tview.NewForm()
+	form.AddInputField("Address", "", 40, nil, nil)
+	form.AddInputField("Name", "", 40, nil, nil)
+	form.AddInputField("Tags", "", 40, nil, nil)
+	form.AddPasswordField("Token", "", 40, '*', nil)
+	form.AddButton("Add", func() {
+		entry := HostEntry{
+			Address: form.GetFormItemByLabel("Address").(*tview.InputField).GetText(),
+			Name:    form.GetFormItemByLabel("Name").(*tview.InputField).GetText(),
+			Token:   form.GetFormItemByLabel("Token").(*tview.InputField).GetText(),
+		}
+		for _, tag := range strings.Split(form.GetFormItemByLabel("Tags").(*tview.InputField).GetText(), ",") {
+			if tag = strings.TrimSpace(tag); len(tag) > 0 {
+				entry.Tags = append(entry.Tags, tag)
+			}
+		}
+		if len(strings.TrimSpace(entry.Address)) == 0 {
+			return
+		}
+		source := hostSourceManual
+		if client.hostsFile != nil {
+			source = hostSourceFile
+		}
+		client.closeModal(pageName)
+		if client.addHost(entry, source) {
+			client.saveHostsFile()
+			client.drawHostsTable()
+		}
+	})
+	form.AddButton("Cancel", func() {
+		client.closeModal(pageName)
+	})
+	form.SetCancelFunc(func() {
+		client.closeModal(pageName)
+	})
+	form.SetBorder(true).SetTitle(" Add Host ").SetTitleAlign(tview.AlignLeft)
+	client.showModal(pageName, form, 60, 13)
+}
@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var streamClient = &http.Client{} // no timeout, event streams stay open while the plot runs
+
+// errResync is returned by streamPlotEvents when the server dropped events, the stream must be reopened.
+var errResync = errors.New("plot events dropped")
+
+// getPlotLog retrieves the full log of a plot from the server, starting at offset.
+func (client *Client) getPlotLog(host *clientHost, plotId string, offset int64) (string, error) {
+	req, err := host.request(context.Background(), "GET", fmt.Sprintf("/plots/%s/log?offset=%d", url.PathEscape(plotId), offset))
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	// servers without the log API answer every GET with a Msg
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		return "", fmt.Errorf("plot log not available: %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	return string(data), err
+}
+
+// loadPlotLog replaces the tail shown in the log pane with the full log of the plot, or appends the
+// lines logged since it was last loaded.  Must be called on the tview thread.
+func (client *Client) loadPlotLog(key string) {
+	host, ok := client.hosts[client.plotHosts[key]]
+	if !ok || len(key) == 0 {
+		return
+	}
+	var offset int64
+	if client.fullLogPlotId == key {
+		offset = client.fullLogSize
+	}
+	go func() {
+		data, err := client.getPlotLog(host, key, offset)
+		if err != nil {
+			return
+		}
+		client.app.QueueUpdateDraw(func() {
+			if client.logPlotId != key {
+				return
+			}
+			if client.fullLogPlotId != key {
+				client.fullLogPlotId = key
+				client.fullLogSize = int64(len(data))
+				client.logTextbox.SetText(data)
+				client.logTextbox.ScrollToEnd()
+			} else if client.fullLogSize == offset {
+				client.fullLogSize += int64(len(data))
+				fmt.Fprint(client.logTextbox, data)
+			}
+		})
+	}()
+}
+
+// followPlotLog subscribes to the log and state events of the selected plot, falling back to
+// loadPlotLog for servers without the events API.  Must be called on the tview thread.
+func (client *Client) followPlotLog(key string) {
+	if client.logCancel != nil {
+		client.logCancel()
+		client.logCancel = nil
+	}
+	client.logStreaming = false
+	host, ok := client.hosts[client.plotHosts[key]]
+	if !ok || len(key) == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	client.logCancel = cancel
+	go func() {
+		err := client.streamPlotEvents(ctx, host, key)
+		client.app.QueueUpdateDraw(func() {
+			if ctx.Err() != nil {
+				return
+			}
+			if err == errResync {
+				// the new stream starts with the full log
+				client.followPlotLog(key)
+				return
+			}
+			client.logStreaming = false
+			if err != nil && client.fullLogPlotId != key {
+				client.loadPlotLog(key)
+			}
+		})
+	}()
+}
+
+// streamPlotEvents reads the server-sent events of a plot until the plot ends or ctx is cancelled,
+// writing the log lines into the log pane.
+func (client *Client) streamPlotEvents(ctx context.Context, host *clientHost, key string) error {
+	req, err := host.request(ctx, "GET", fmt.Sprintf("/plots/%s/events", url.PathEscape(key)))
+	if err != nil {
+		return err
+	}
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return fmt.Errorf("plot events not available: %s", resp.Status)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	var pending strings.Builder
+	var state *PlotEvent
+	size := int64(-1)
+	first := true
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(line, "data: ") {
+			var ev PlotEvent
+			if err := json.Unmarshal([]byte(line[6:]), &ev); err != nil {
+				return err
+			}
+			switch ev.Kind {
+			case PlotEventLog:
+				pending.WriteString(ev.Line)
+				if ev.Offset >= 0 {
+					size = ev.Offset + int64(len(ev.Line))
+				}
+			case PlotEventState:
+				state = &ev
+			case PlotEventResync:
+				return errResync
+			}
+		}
+		// batch everything already received into a single UI update
+		if reader.Buffered() > 0 || (pending.Len() == 0 && state == nil) {
+			continue
+		}
+		text, newState, newSize, replace := pending.String(), state, size, first
+		client.app.QueueUpdateDraw(func() {
+			if ctx.Err() != nil {
+				return
+			}
+			client.logStreaming = true
+			client.fullLogPlotId = key
+			if newSize >= 0 {
+				client.fullLogSize = newSize
+			}
+			if replace {
+				client.logTextbox.SetText(text)
+				client.logTextbox.ScrollToEnd()
+			} else if len(text) > 0 {
+				fmt.Fprint(client.logTextbox, text)
+			}
+			if newState != nil {
+				client.logTextbox.SetTitle(fmt.Sprintf(" Log (%s) - %s %s %s ", shortenPlotId(key), stateString(newState.State), newState.Phase, newState.Progress))
+			}
+		})
+		pending.Reset()
+		state = nil
+		first = false
+	}
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// schedulerRefreshInterval is how often the scheduler panel is refreshed while the host is polled.
+const schedulerRefreshInterval = 30 * time.Second
+
+// getSchedulerLog retrieves the latest scheduler decisions of a host.
+func getSchedulerLog(host *clientHost) ([]SchedulerEntry, error) {
+	req, err := host.request(context.Background(), "GET", "/api/scheduler")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// servers without the scheduler API answer every GET with a Msg
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		return nil, fmt.Errorf("scheduler history not available: %s", resp.Status)
+	}
+	var entries []SchedulerEntry
+	err = json.NewDecoder(resp.Body).Decode(&entries)
+	return entries, err
+}
+
+// selectHost shows the scheduler decisions of the host selected in the Hosts table.
+func (client *Client) selectHost(key string) {
+	client.schedulerHost = key
+	client.schedulerView.SetTitle(fmt.Sprintf(" Scheduler (%s) ", key))
+	client.schedulerView.SetText("")
+	client.schedulerLoaded = time.Time{}
+	client.loadSchedulerLog()
+}
+
+// loadSchedulerLog fetches the scheduler decisions of the selected host, if the panel is shown and
+// they were not fetched recently.  Must be called on the tview thread.
+func (client *Client) loadSchedulerLog() {
+	host, ok := client.hosts[client.schedulerHost]
+	if !ok || !client.isPanelShown(panelScheduler) || time.Since(client.schedulerLoaded) < schedulerRefreshInterval {
+		return
+	}
+	client.schedulerLoaded = time.Now()
+	name := client.schedulerHost
+	go func() {
+		entries, err := getSchedulerLog(host)
+		client.app.QueueUpdateDraw(func() {
+			if client.schedulerHost != name {
+				return
+			}
+			if err != nil {
+				client.schedulerView.SetText(err.Error())
+			} else {
+				client.schedulerView.SetText(schedulerLogText(entries))
+			}
+			client.schedulerView.ScrollToBeginning()
+		})
+	}()
+}
+
+// schedulerLogText lists the scheduler decisions, the latest first.
+func schedulerLogText(entries []SchedulerEntry) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		when := entry.Time.Format("2006-01-02 15:04")
+		if entry.Count > 1 {
+			when += entry.LastTime.Format(" - 15:04")
+			if entry.LastTime.YearDay() != entry.Time.YearDay() {
+				when = entry.Time.Format("2006-01-02 15:04") + entry.LastTime.Format(" - 01-02 15:04")
+			}
+		}
+		repeated := ""
+		if entry.Count > 1 {
+			repeated = fmt.Sprintf("x%d", entry.Count)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", when, repeated, entry.Status)
+	}
+	w.Flush()
+	return buf.String()
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	statsPage      = "stats"
+	sparklineDays  = 14
+	rankedTempDirs = 3 // fastest and slowest temp dirs shown
+	allHostsName   = "All hosts"
+)
+
+var statsPercentiles = []float64{10, 50, 90}
+
+// tableKeys handles the keys shared by the tables: s shows the statistics, then the keys shared by the panels.
+func (client *Client) tableKeys(event *tcell.EventKey) *tcell.EventKey {
+	if event.Rune() == 's' {
+		client.showStats()
+		return nil
+	}
+	return client.panelKeys(event)
+}
+
+func (client *Client) showStats() {
+	if client.statsView == nil {
+		client.statsView = tview.NewTextView()
+		client.statsView.SetBorder(true)
+		client.statsView.SetTitleAlign(tview.AlignLeft)
+		client.statsView.SetTitle(" Statistics (s or Esc to close) ")
+		client.statsView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Rune() == 's' || event.Key() == tcell.KeyEscape {
+				client.closeModal(statsPage)
+				return nil
+			}
+			return event
+		})
+	}
+	if client.modalFocus == nil {
+		client.modalFocus = client.app.GetFocus()
+	}
+	client.pages.AddPage(statsPage, client.statsView, true, true)
+	client.app.SetFocus(client.statsView)
+	client.drawStats()
+}
+
+// drawStats updates the statistics page if it is shown.
+func (client *Client) drawStats() {
+	if client.statsView == nil || !client.pages.HasPage(statsPage) {
+		return
+	}
+	now := time.Now()
+	archives := map[string][]*ActivePlot{}
+	var all []*ActivePlot
+	var hosts []string
+	for host, msg := range client.msg {
+		if len(msg.Archived) > 0 {
+			archives[host] = msg.Archived
+			all = append(all, msg.Archived...)
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 3, ' ', 0)
+	fmt.Fprintf(w, "Throughput\tPlots/day 24h\tTB/day 24h\tPlots/day 7d\tTB/day 7d\tFinished per day (last %d days)\n", sparklineDays)
+	writeThroughput := func(name string, archive []*ActivePlot) {
+		day := finishedThroughput(archive, now, 24*time.Hour)
+		week := finishedThroughput(archive, now, 7*24*time.Hour)
+		fmt.Fprintf(w, "%s\t%.1f\t%.2f\t%.1f\t%.2f\t%s\n", name, day.PlotsPerDay(), day.TBPerDay(), week.PlotsPerDay(), week.TBPerDay(),
+			sparkline(plotsPerDay(archive, now, sparklineDays)))
+	}
+	writeThroughput(allHostsName, all)
+	for _, host := range hosts {
+		writeThroughput(host, archives[host])
+	}
+
+	percentiles, count := phasePercentiles(all, statsPercentiles)
+	fmt.Fprintf(w, "\nPhase durations (%d finished plots)", count)
+	for _, p := range statsPercentiles {
+		fmt.Fprintf(w, "\tp%.0f", p)
+	}
+	fmt.Fprintln(w)
+	for phase := 0; phase < phaseCount; phase++ {
+		name := fmt.Sprintf("Phase %d", phase+1)
+		if phase == phaseCount-1 {
+			name = "Copying"
+		}
+		fmt.Fprint(w, name)
+		for _, d := range percentiles[phase] {
+			fmt.Fprintf(w, "\t%s", DurationString(d))
+		}
+		fmt.Fprintln(w)
+	}
+
+	ranked := rankTempDirs(archives)
+	writeTempDirs := func(title string, dirs []tempDirStats) {
+		fmt.Fprintf(w, "\n%s\tHost\tAvg Plot Time\tCount\tFailed\n", title)
+		for _, s := range dirs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", s.PlotDir, s.Host, DurationString(s.AvgTotal), s.Count, s.Failed)
+		}
+	}
+	if len(ranked) > 2*rankedTempDirs {
+		writeTempDirs("Fastest temp dirs", ranked[:rankedTempDirs])
+		slowest := append([]tempDirStats{}, ranked[len(ranked)-rankedTempDirs:]...)
+		sort.SliceStable(slowest, func(i, j int) bool { return slowest[i].AvgTotal > slowest[j].AvgTotal })
+		writeTempDirs("Slowest temp dirs", slowest)
+	} else {
+		writeTempDirs("Temp dirs, fastest first", ranked)
+	}
+	w.Flush()
+	client.statsView.SetText(buf.String())
+}
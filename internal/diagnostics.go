@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Rules of the scheduler, as named in a Diagnosis.
+const (
+	ruleConfig   = "config"
+	ruleDelay    = "delay"
+	ruleParallel = "parallel"
+	rulePhase1   = "phase1"
+	ruleTemp     = "temp"
+	ruleTarget   = "target"
+	ruleSpace    = "space"
+)
+
+// DiagnosisRule is a scheduler rule which blocks new plots, either every plot (no Dir) or those using
+// Dir as temp or target directory.
+type DiagnosisRule struct {
+	Rule   string
+	Dir    string
+	Reason string
+}
+
+// PairDiagnosis tells whether a plot could be started with a temp and a target directory, and the rules
+// blocking it otherwise.
+type PairDiagnosis struct {
+	TempDir   string
+	TargetDir string
+	Eligible  bool
+	Blocked   []string
+}
+
+// Diagnosis evaluates every rule of the scheduler for every temp and target directory pair, unlike
+// canCreateNewPlot which stops at the first failing rule.
+type Diagnosis struct {
+	Time       time.Time
+	TempDirs   []string
+	TargetDirs []string
+	NextTemp   string // directories the scheduler tries next
+	NextTarget string
+	Rules      []DiagnosisRule
+	Pairs      []PairDiagnosis
+}
+
+// diagnose evaluates the rules of canCreateNewPlot without changing the state of the scheduler.  Must
+// be called with the server lock held.
+func (server *Server) diagnose(config *Config, now time.Time) *Diagnosis {
+	d := &Diagnosis{
+		Time:       now,
+		TempDirs:   config.TempDirectory,
+		TargetDirs: config.TargetDirectory,
+	}
+	if len(config.TempDirectory) == 0 || len(config.TargetDirectory) == 0 {
+		d.Rules = append(d.Rules, DiagnosisRule{Rule: ruleConfig, Reason: "configuration lacks TempDirectory or TargetDirectory"})
+		return d
+	}
+	d.NextTemp = config.TempDirectory[server.pickTemp(config)]
+	d.NextTarget = config.TargetDirectory[server.currentTarget%len(config.TargetDirectory)]
+
+	if now.Before(server.targetDelayStartTime) {
+		d.Rules = append(d.Rules, DiagnosisRule{Rule: ruleDelay,
+			Reason: fmt.Sprintf("waiting until %s (DelaysBetweenPlot or StaggeringDelay)", server.targetDelayStartTime.Format("2006-01-02 15:04:05"))})
+	} else if server.currentTarget >= len(config.TargetDirectory) {
+		d.Rules = append(d.Rules, DiagnosisRule{Rule: ruleDelay,
+			Reason: fmt.Sprintf("every target directory was used, the next check starts the StaggeringDelay of %d minutes", config.StaggeringDelay)})
+	}
+	if active := server.countActivePlots(); active >= config.NumberOfParallelPlots {
+		d.Rules = append(d.Rules, DiagnosisRule{Rule: ruleParallel,
+			Reason: fmt.Sprintf("running %d/%d plots (NumberOfParallelPlots)", active, config.NumberOfParallelPlots)})
+	}
+	if config.MaxActivePlotPerPhase1 > 0 {
+		sum := 0
+		for _, plot := range server.snapshots() {
+			if (plot.State == PlotRunning || plot.State == PlotStalled) && plot.getCurrentPhase() <= 1 {
+				sum++
+			}
+		}
+		if sum >= config.MaxActivePlotPerPhase1 {
+			d.Rules = append(d.Rules, DiagnosisRule{Rule: rulePhase1,
+				Reason: fmt.Sprintf("%d/%d plots in phase 1 (MaxActivePlotPerPhase1)", sum, config.MaxActivePlotPerPhase1)})
+		}
+	}
+	global := len(d.Rules)
+
+	blocked := map[string][]string{}
+	for _, dir := range config.TempDirectory {
+		if active := server.countActiveTemp(dir); config.MaxActivePlotPerTemp > 0 && active >= config.MaxActivePlotPerTemp {
+			d.Rules = append(d.Rules, DiagnosisRule{Rule: ruleTemp, Dir: dir,
+				Reason: fmt.Sprintf("%d/%d plots (MaxActivePlotPerTemp)", active, config.MaxActivePlotPerTemp)})
+			blocked["temp:"+dir] = append(blocked["temp:"+dir], ruleTemp)
+		}
+	}
+	for _, dir := range config.TargetDirectory {
+		active := server.countActiveTarget(dir)
+		if config.MaxActivePlotPerTarget > 0 && active >= config.MaxActivePlotPerTarget {
+			d.Rules = append(d.Rules, DiagnosisRule{Rule: ruleTarget, Dir: dir,
+				Reason: fmt.Sprintf("%d/%d plots (MaxActivePlotPerTarget)", active, config.MaxActivePlotPerTarget)})
+			blocked["target:"+dir] = append(blocked["target:"+dir], ruleTarget)
+		}
+		if space := server.getCachedDiskSpaceAvailable(dir); config.DiskSpaceCheck && uint64(active+1)*PLOT_SIZE > space {
+			d.Rules = append(d.Rules, DiagnosisRule{Rule: ruleSpace, Dir: dir,
+				Reason: fmt.Sprintf("%s available for %d plots (DiskSpaceCheck)", SpaceString(space), active+1)})
+			blocked["target:"+dir] = append(blocked["target:"+dir], ruleSpace)
+		}
+	}
+
+	for _, temp := range config.TempDirectory {
+		for _, target := range config.TargetDirectory {
+			pair := PairDiagnosis{TempDir: temp, TargetDir: target, Blocked: []string{}}
+			for _, rule := range d.Rules[:global] {
+				pair.Blocked = append(pair.Blocked, rule.Rule)
+			}
+			pair.Blocked = append(pair.Blocked, blocked["temp:"+temp]...)
+			pair.Blocked = append(pair.Blocked, blocked["target:"+target]...)
+			pair.Eligible = len(pair.Blocked) == 0
+			d.Pairs = append(d.Pairs, pair)
+		}
+	}
+	return d
+}
+
+// serveDiagnostics handles /api/diagnostics.
+func (server *Server) serveDiagnostics(resp http.ResponseWriter, req *http.Request) {
+	server.config.Lock.RLock()
+	defer server.config.Lock.RUnlock()
+	if server.config.CurrentConfig == nil {
+		http.Error(resp, "configuration not loaded", http.StatusServiceUnavailable)
+		return
+	}
+	server.lock.RLock()
+	defer server.lock.RUnlock()
+	writeJSON(resp, server.diagnose(server.config.CurrentConfig, server.clock.Now()))
+}
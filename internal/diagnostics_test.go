@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiagnose(t *testing.T) {
+	config := &Config{
+		TempDirectory:          []string{"/tmp1", "/tmp2"},
+		TargetDirectory:        []string{"/dest1", "/dest2"},
+		NumberOfParallelPlots:  4,
+		MaxActivePlotPerTemp:   1,
+		MaxActivePlotPerTarget: 2,
+		DiskSpaceCheck:         true,
+	}
+	now := time.Now()
+	svr := &Server{
+		config: &PlotConfig{CurrentConfig: config},
+		active: map[int64]*ActivePlot{
+			1: {PlotDir: "/tmp1", TargetDir: "/dest1", State: PlotRunning},
+		},
+		diskSpace: map[string]diskSpaceEntry{
+			"/dest1": {available: 500 * GB, checked: now},
+			"/dest2": {available: 50 * GB, checked: now},
+		},
+		currentTemp:   1,
+		currentTarget: 1,
+	}
+
+	d := svr.diagnose(config, now)
+	blocked := map[string][]string{}
+	for _, pair := range d.Pairs {
+		if pair.Eligible != (len(pair.Blocked) == 0) {
+			t.Errorf("pair %+v eligible does not match its rules", pair)
+		}
+		blocked[pair.TempDir+" "+pair.TargetDir] = pair.Blocked
+	}
+	expected := map[string][]string{
+		"/tmp1 /dest1": {ruleTemp},
+		"/tmp1 /dest2": {ruleTemp, ruleSpace},
+		"/tmp2 /dest1": {},
+		"/tmp2 /dest2": {ruleSpace},
+	}
+	if !reflect.DeepEqual(blocked, expected) {
+		t.Errorf("expected %v, got %v", expected, blocked)
+	}
+	if d.NextTemp != "/tmp2" || d.NextTarget != "/dest2" {
+		t.Errorf("unexpected next pair %s %s", d.NextTemp, d.NextTarget)
+	}
+
+	// a delay blocks every pair, and the scheduler state is unchanged
+	svr.targetDelayStartTime = now.Add(time.Minute)
+	config.NumberOfParallelPlots = 1
+	d = svr.diagnose(config, now)
+	for _, pair := range d.Pairs {
+		if pair.Eligible || len(pair.Blocked) < 2 || pair.Blocked[0] != ruleDelay || pair.Blocked[1] != ruleParallel {
+			t.Errorf("unexpected pair %+v", pair)
+		}
+	}
+	if svr.currentTemp != 1 || svr.currentTarget != 1 || !svr.targetDelayStartTime.Equal(now.Add(time.Minute)) {
+		t.Errorf("diagnose changed the scheduler state")
+	}
+
+	resp := httptest.NewRecorder()
+	svr.ServeHTTP(resp, httptest.NewRequest("GET", "/api/diagnostics", nil))
+	var served Diagnosis
+	if err := json.NewDecoder(resp.Body).Decode(&served); err != nil || len(served.Pairs) != 4 || len(served.Rules) != len(d.Rules) {
+		t.Errorf("unexpected diagnostics %+v: %v", served, err)
+	}
+	text := diagnosisText(&served)
+	if !strings.Contains(text, "delay,parallel,temp") || !strings.Contains(text, "NumberOfParallelPlots") {
+		t.Errorf("unexpected text\n%s", text)
+	}
+}
@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"time"
+)
+
+// Plotters announce themselves on the LAN with a UDP broadcast, which plotng-client listens for.
+const (
+	discoveryPort     = 8483
+	discoveryService  = "plotng"
+	announcementDelay = 30 * time.Second
+)
+
+type announcement struct {
+	Service string
+	Name    string
+	Port    int
+}
+
+// announce broadcasts the server on the LAN until ctx is done.
+func announce(ctx context.Context, name string, port int) {
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: net.IPv4bcast, Port: discoveryPort})
+	if err != nil {
+		log.Printf("Failed to announce server: %s", err)
+		return
+	}
+	defer conn.Close()
+	data, _ := json.Marshal(announcement{Service: discoveryService, Name: name, Port: port})
+	for ctx.Err() == nil {
+		if _, err := conn.Write(data); err != nil {
+			log.Printf("Failed to announce server: %s", err)
+		}
+		sleepContext(ctx, announcementDelay)
+	}
+}
+
+// listenForAnnouncements calls found with every server announced on the LAN until ctx is done.
+func listenForAnnouncements(ctx context.Context, found func(host HostEntry)) error {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: discoveryPort})
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	buf := make([]byte, 1024)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		var ann announcement
+		if err := json.Unmarshal(buf[:n], &ann); err != nil || ann.Service != discoveryService {
+			continue
+		}
+		found(HostEntry{
+			Name:    ann.Name,
+			Address: fmt.Sprintf("%s:%d", addr.IP, ann.Port),
+			Tags:    []string{"discovered"},
+		})
+	}
+}
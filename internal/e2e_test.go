@@ -0,0 +1,245 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testToken = "e2e-token"
+
+// testPlotter is a Server plotting with the fake plotter, whose scheduler is run by the test instead of
+// every minute.
+type testPlotter struct {
+	t       *testing.T
+	server  *Server
+	http    *httptest.Server
+	tempDir string
+	destDir string
+}
+
+// newTestPlotter starts a Server with the fake plotter as chia, or as MadMax, and a configuration using
+// a temp and a dest directory of its own.
+func newTestPlotter(t *testing.T, madmax bool, options fakePlotterOptions) *testPlotter {
+	t.Helper()
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	tp := &testPlotter{t: t, tempDir: filepath.Join(dir, "temp"), destDir: filepath.Join(dir, "dest")}
+	binDir := filepath.Join(dir, "bin")
+	for _, d := range []string{tp.tempDir, tp.destDir, binDir} {
+		if err := os.Mkdir(d, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if options.Transcript, err = filepath.Abs(options.Transcript); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(options)
+	if err := ioutil.WriteFile(filepath.Join(tp.tempDir, fakePlotterOptionsFile), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := Config{
+		TempDirectory:         []string{tp.tempDir},
+		TargetDirectory:       []string{tp.destDir},
+		NumberOfParallelPlots: 1,
+		AuthToken:             testToken,
+		ChiaRoot:              binDir,
+	}
+	plotter := filepath.Join(binDir, "chia")
+	if madmax {
+		plotter = filepath.Join(binDir, "chia_plot")
+		config.MadMaxPlotter = plotter
+	}
+	if err := os.Symlink(executable, plotter); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "config.json")
+	if err := (&PlotConfig{ConfigPath: configPath}).SaveConfig(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	tp.server = &Server{}
+	tp.server.setup(configPath, "plotter1")
+	tp.http = httptest.NewServer(tp.server)
+	t.Cleanup(func() {
+		// plots still running write their logs in dir until they end
+		tp.server.lock.RLock()
+		defer tp.server.lock.RUnlock()
+		for _, plot := range tp.server.active {
+			if !plot.running() {
+				continue
+			}
+			plot.kill()
+			for deadline := time.Now().Add(5 * time.Second); plot.running() && time.Now().Before(deadline); {
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+		tp.http.Close()
+	})
+	return tp
+}
+
+// tick runs the scheduler once, as done every minute by ProcessLoop.
+func (tp *testPlotter) tick() {
+	tp.server.createPlot(time.Now())
+}
+
+func (tp *testPlotter) host() string {
+	return strings.TrimPrefix(tp.http.URL, "http://")
+}
+
+// msg gets the state of the plotter as plotng-client does.
+func (tp *testPlotter) msg() *Msg {
+	tp.t.Helper()
+	msg, _, err := fetchMsg(context.Background(), tp.host(), testToken, url.Values{})
+	if err != nil {
+		tp.t.Fatal(err)
+	}
+	return msg
+}
+
+// waitFor polls the state of the plotter until done returns true, for up to 10 seconds.
+func (tp *testPlotter) waitFor(what string, done func(msg *Msg) bool) *Msg {
+	tp.t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		msg := tp.msg()
+		if done(msg) {
+			return msg
+		}
+		if time.Now().After(deadline) {
+			tp.t.Fatalf("timed out waiting for %s, last state: %+v", what, msg)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// get returns the body of a request to the plotter.
+func (tp *testPlotter) get(method string, path string) string {
+	tp.t.Helper()
+	req, err := newHostRequest(context.Background(), method, tp.host(), testToken, path)
+	if err != nil {
+		tp.t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		tp.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		tp.t.Fatalf("%s %s: %s %s", method, path, resp.Status, body)
+	}
+	return string(body)
+}
+
+// tmpFiles returns the temporary files left in the temp directory.
+func (tp *testPlotter) tmpFiles() []string {
+	files, _ := filepath.Glob(filepath.Join(tp.tempDir, "*.tmp"))
+	return files
+}
+
+func activeState(state int) func(msg *Msg) bool {
+	return func(msg *Msg) bool {
+		return len(msg.Actives) == 1 && msg.Actives[0].State == state
+	}
+}
+
+func TestEndToEndChia(t *testing.T) {
+	tp := newTestPlotter(t, false, fakePlotterOptions{Transcript: "testdata/chia.log"})
+	tp.tick()
+	msg := tp.waitFor("the plot to finish", activeState(PlotFinished))
+	plot := msg.Actives[0]
+	const id = "3f1d9c1a2b4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8"
+	finalPath := filepath.Join(tp.destDir, "plot-k32-2021-06-14-09-59-"+id+".plot")
+	if plot.Id != id || plot.Phase != "cp" || plot.PlotDir != tp.tempDir || plot.TargetDir != tp.destDir {
+		t.Errorf("unexpected plot %+v", plot)
+	}
+	for phase := 1; phase <= phaseCount; phase++ {
+		if plot.getPhaseTime(phase).IsZero() {
+			t.Errorf("missing end of phase %d", phase)
+		}
+	}
+	if !strings.Contains(plot.CommandLine, "plots create -n1 -t "+tp.tempDir) {
+		t.Errorf("unexpected command line %s", plot.CommandLine)
+	}
+	if len(plot.TableTimings) != 13 || plot.FinalPath != finalPath || plot.FinalSize != 4 {
+		t.Errorf("unexpected details: %v %s %d", plot.TableTimings, plot.FinalPath, plot.FinalSize)
+	}
+	if files := tp.tmpFiles(); len(files) != 0 {
+		t.Errorf("unexpected temporary files %v", files)
+	}
+
+	tp.tick()
+	msg = tp.msg()
+	if len(msg.Actives) != 0 || len(msg.Archived) != 1 || msg.Archived[0].Id != id {
+		t.Fatalf("expected the plot to be archived: %+v", msg)
+	}
+	if log := tp.get("GET", "/plots/"+id+"/log"); !strings.HasPrefix(log, "2021-06-14T09:59:12.345") || !strings.Contains(log, finalPath) {
+		t.Errorf("unexpected log\n%s", log)
+	}
+	if state := tp.get("GET", "/api/state"); !strings.Contains(state, id) {
+		t.Errorf("unexpected state %s", state)
+	}
+
+	// every target directory was used, so the next tick staggers and the one after starts another plot
+	tp.tick()
+	if msg := tp.msg(); !strings.HasPrefix(msg.Status, "staggering start until") {
+		t.Errorf("unexpected status %s", msg.Status)
+	}
+	tp.tick()
+	tp.waitFor("a second plot", func(msg *Msg) bool { return len(msg.Actives) == 1 && len(msg.Archived) == 1 })
+}
+
+func TestEndToEndMadMax(t *testing.T) {
+	tp := newTestPlotter(t, true, fakePlotterOptions{Transcript: "testdata/madmax.log"})
+	tp.tick()
+	msg := tp.waitFor("the plot to finish", activeState(PlotFinished))
+	plot := msg.Actives[0]
+	const id = "7a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9"
+	if plot.Id != id || plot.Phase != "cp" || plot.Progress != "100%" || plot.Phase4Time.IsZero() {
+		t.Errorf("unexpected plot %+v", plot)
+	}
+	if len(plot.TableTimings) != 13 || plot.FinalPath != filepath.Join(tp.destDir, "plot-k32-2021-06-14-09-59-"+id+".plot") || plot.FinalSize != 4 {
+		t.Errorf("unexpected details: %v %s %d", plot.TableTimings, plot.FinalPath, plot.FinalSize)
+	}
+}
+
+func TestEndToEndError(t *testing.T) {
+	tp := newTestPlotter(t, false, fakePlotterOptions{Transcript: "testdata/chia.log", ExitAfter: 20, ExitCode: 1})
+	tp.tick()
+	msg := tp.waitFor("the plot to fail", func(msg *Msg) bool {
+		return activeState(PlotError)(msg) && len(tp.tmpFiles()) == 0
+	})
+	if plot := msg.Actives[0]; plot.Phase != "1/4" || plot.Progress != "12%" {
+		t.Errorf("unexpected plot %+v", plot)
+	}
+	tp.tick()
+	if msg := tp.msg(); len(msg.Archived) != 1 || msg.Archived[0].State != PlotError {
+		t.Errorf("expected the failed plot to be archived: %+v", msg)
+	}
+}
+
+func TestEndToEndKill(t *testing.T) {
+	tp := newTestPlotter(t, false, fakePlotterOptions{Transcript: "testdata/chia.log", ExitAfter: 20, Hang: true})
+	tp.tick()
+	msg := tp.waitFor("the plot to start", func(msg *Msg) bool {
+		return activeState(PlotRunning)(msg) && len(msg.Actives[0].Id) > 0 && len(tp.tmpFiles()) == 1
+	})
+	tp.get("DELETE", "/plots/"+msg.Actives[0].Id)
+	tp.waitFor("the plot to be killed", func(msg *Msg) bool {
+		return activeState(PlotKilled)(msg) && len(tp.tmpFiles()) == 0
+	})
+}
@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	PlotEventLog    = "log"
+	PlotEventState  = "state"
+	PlotEventServer = "server" // change to the server state, such as its status or archive
+	PlotEventResync = "resync" // events were dropped, the log must be reloaded from Offset
+	PlotEventTail   = "tail"   // lines were added to the Tail of the plot, published at most every second
+)
+
+// PlotEvent is published when a plot logs a line or changes state.
+type PlotEvent struct {
+	PlotId   int64
+	Id       string
+	Kind     string
+	Line     string
+	Offset   int64 // offset of Line in the full plot log
+	State    int
+	Phase    string
+	Progress string
+	Seq      uint64 // assigned by the hub, increases with every event other than a log line
+}
+
+const maxStateWait = 60 * time.Second
+
+// eventHub fans out plot events to subscribers.  Subscribers which fall behind are dropped rather than
+// blocking the plot, their channel being closed once the events already queued are received.
+//
+// Log lines do not advance the sequence: the clients polling the state learn about them through the
+// PlotEventTail coalescing the lines of a second, instead of being woken up for every line.
+type eventHub struct {
+	lock        sync.Mutex
+	seq         uint64
+	subscribers map[chan PlotEvent]bool // whether the subscriber receives the log lines
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		subscribers: map[chan PlotEvent]bool{},
+	}
+}
+
+func (hub *eventHub) subscribe() chan PlotEvent {
+	return hub.subscribeEvents(true)
+}
+
+// subscribeEvents subscribes to the events, leaving out the log lines unless logs is set.
+func (hub *eventHub) subscribeEvents(logs bool) chan PlotEvent {
+	ch := make(chan PlotEvent, 256)
+	hub.lock.Lock()
+	hub.subscribers[ch] = logs
+	hub.lock.Unlock()
+	return ch
+}
+
+func (hub *eventHub) unsubscribe(ch chan PlotEvent) {
+	hub.lock.Lock()
+	delete(hub.subscribers, ch)
+	hub.lock.Unlock()
+}
+
+// publish sends the event to every subscriber, returning the sequence number assigned to it, or 0 for a
+// log line.
+func (hub *eventHub) publish(ev PlotEvent) uint64 {
+	if hub == nil {
+		return 0
+	}
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	seq := uint64(0)
+	if ev.Kind != PlotEventLog {
+		hub.seq++
+		seq = hub.seq
+	}
+	ev.Seq = hub.seq
+	for ch, logs := range hub.subscribers {
+		if ev.Kind == PlotEventLog && !logs {
+			continue
+		}
+		select {
+		case ch <- ev:
+		default:
+			delete(hub.subscribers, ch)
+			close(ch)
+		}
+	}
+	return seq
+}
+
+// currentSeq returns the sequence number of the last event published.
+func (hub *eventHub) currentSeq() uint64 {
+	if hub == nil {
+		return 0
+	}
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	return hub.seq
+}
+
+// waitForChange blocks until an event newer than since is published, the wait expires or the client goes away.
+func (hub *eventHub) waitForChange(ctx context.Context, since uint64, wait time.Duration) {
+	if hub == nil {
+		return
+	}
+	if wait > maxStateWait {
+		wait = maxStateWait
+	}
+	ch := hub.subscribeEvents(false)
+	defer hub.unsubscribe(ch)
+	if hub.currentSeq() > since {
+		return
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ch:
+	case <-timer.C:
+		return
+	case <-ctx.Done():
+		return
+	}
+	// changes come in bursts, such as a phase change and the tail of its lines, so gather them into one response
+	select {
+	case <-time.After(time.Second):
+	case <-ctx.Done():
+	}
+}
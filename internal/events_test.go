@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServePlotEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plotng")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	svr := &Server{events: newEventHub(), active: map[int64]*ActivePlot{}}
+	plot := &ActivePlot{PlotId: 42, Id: "abc", State: PlotRunning, Phase: "1/4", logStore: &LogStore{Dir: dir}, events: svr.events}
+	svr.active[plot.PlotId] = plot
+	plot.openFullLog()
+	plot.appendLog("ID: abc\n")
+
+	stub := httptest.NewServer(svr)
+	defer stub.Close()
+	resp, err := http.Get(stub.URL + "/plots/abc/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected content type: %s", resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan PlotEvent, 10)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if strings.HasPrefix(scanner.Text(), "data: ") {
+				var ev PlotEvent
+				json.Unmarshal([]byte(scanner.Text()[6:]), &ev)
+				events <- ev
+			}
+		}
+		close(events)
+	}()
+	next := func() PlotEvent {
+		t.Helper()
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event received")
+		}
+		return PlotEvent{}
+	}
+
+	if ev := next(); ev.Kind != PlotEventLog || ev.Line != "ID: abc\n" || ev.Offset != 0 {
+		t.Errorf("unexpected backlog event: %+v", ev)
+	}
+	if ev := next(); ev.Kind != PlotEventState || ev.Phase != "1/4" {
+		t.Errorf("unexpected state event: %+v", ev)
+	}
+	plot.appendLog("Starting phase 2/4\n")
+	if ev := next(); ev.Kind != PlotEventLog || ev.Line != "Starting phase 2/4\n" || ev.Offset != 8 {
+		t.Errorf("unexpected live event: %+v", ev)
+	}
+	plot.setState(PlotFinished)
+	if ev := next(); ev.Kind != PlotEventState || ev.State != PlotFinished {
+		t.Errorf("unexpected final event: %+v", ev)
+	}
+	if _, ok := <-events; ok {
+		t.Error("stream should end with the plot")
+	}
+}
+
+// blockedWriter is a ResponseWriter whose writes wait while gate is locked, as for a slow client.
+type blockedWriter struct {
+	gate   sync.Mutex
+	header http.Header
+	body   strings.Builder
+}
+
+func (bw *blockedWriter) Header() http.Header { return bw.header }
+func (bw *blockedWriter) WriteHeader(int)     {}
+func (bw *blockedWriter) Flush()              {}
+
+func (bw *blockedWriter) Write(data []byte) (int, error) {
+	bw.gate.Lock()
+	defer bw.gate.Unlock()
+	return bw.body.Write(data)
+}
+
+func TestServePlotEventsResync(t *testing.T) {
+	svr := &Server{events: newEventHub(), active: map[int64]*ActivePlot{}}
+	plot := &ActivePlot{PlotId: 42, Id: "abc", State: PlotRunning, events: svr.events}
+	svr.active[plot.PlotId] = plot
+
+	resp := &blockedWriter{header: http.Header{}}
+	resp.gate.Lock()
+	done := make(chan struct{})
+	go func() {
+		svr.servePlotEvents(resp, httptest.NewRequest("GET", "/plots/abc/events", nil), plot)
+		close(done)
+	}()
+	for subscribed := false; !subscribed; time.Sleep(time.Millisecond) {
+		svr.events.lock.Lock()
+		subscribed = len(svr.events.subscribers) > 0
+		svr.events.lock.Unlock()
+	}
+	for i := 0; i < 300; i++ {
+		plot.appendLog(fmt.Sprintf("line %d\n", i))
+	}
+	resp.gate.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream should end once events were dropped")
+	}
+	if body := resp.body.String(); !strings.Contains(body, "event: resync\n") {
+		t.Errorf("expected a resync event, got %q", body)
+	}
+}
+
+func TestLogLinesCoalesced(t *testing.T) {
+	hub := newEventHub()
+	plot := &ActivePlot{PlotId: 42, Id: "abc", State: PlotRunning, events: hub}
+	plot.setState(PlotRunning)
+	version := plot.getVersion()
+
+	start := time.Now()
+	changed := make(chan struct{})
+	go func() {
+		hub.waitForChange(context.Background(), version, 5*time.Second)
+		close(changed)
+	}()
+	for i := 0; i < 10; i++ {
+		plot.appendLog(fmt.Sprintf("line %d\n", i))
+	}
+	if plot.getVersion() != version || hub.currentSeq() != version {
+		t.Error("log lines should not change the version right away")
+	}
+	<-changed
+	if plot.getVersion() != version+1 {
+		t.Errorf("the lines should change the version once, got %d after %d", plot.getVersion(), version)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second || elapsed > 4*time.Second {
+		t.Errorf("unexpected wait of %s", elapsed)
+	}
+}
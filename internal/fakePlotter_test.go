@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakePlotterOptions is read by the fake plotter from fakePlotterOptionsFile in its temp directory, so
+// that each plot of a test can behave differently.
+type fakePlotterOptions struct {
+	Transcript string // log replayed, where {temp} and {dest} are replaced by the directories of the plot
+	LineDelay  time.Duration
+	ExitAfter  int // lines written before exiting with ExitCode, 0 for the whole transcript
+	ExitCode   int
+	Hang       bool // wait to be killed after ExitAfter lines instead of exiting
+}
+
+const fakePlotterOptionsFile = "fakeplotter.json"
+
+// TestMain runs the test binary as the fake plotter when the end-to-end tests start it as chia or
+// chia_plot.
+func TestMain(m *testing.M) {
+	switch filepath.Base(os.Args[0]) {
+	case "chia", "chia_plot":
+		os.Exit(runFakePlotter(os.Args[1:], os.Stdout))
+	}
+	os.Exit(m.Run())
+}
+
+// runFakePlotter replays a transcript like the chia or MadMax plotter: it creates a temporary file named
+// after the plot id once the id is logged, creates the final file once it is logged, and removes the
+// temporary file when the transcript ends.
+func runFakePlotter(args []string, out io.Writer) int {
+	var tempDir, destDir string
+	for i := 0; i+1 < len(args); i++ {
+		switch args[i] {
+		case "-t":
+			tempDir = args[i+1]
+		case "-d":
+			destDir = args[i+1]
+		}
+	}
+	var options fakePlotterOptions
+	data, err := ioutil.ReadFile(filepath.Join(tempDir, fakePlotterOptionsFile))
+	if err == nil {
+		err = json.Unmarshal(data, &options)
+	}
+	if err != nil {
+		fmt.Fprintf(out, "fake plotter: invalid options: %s\n", err)
+		return 2
+	}
+	transcript, err := os.Open(options.Transcript)
+	if err != nil {
+		fmt.Fprintf(out, "fake plotter: %s\n", err)
+		return 2
+	}
+	defer transcript.Close()
+
+	replacer := strings.NewReplacer("{temp}", tempDir, "{dest}", destDir)
+	scanner := bufio.NewScanner(transcript)
+	var tmpFile string
+	for lines := 0; scanner.Scan(); lines++ {
+		if options.ExitAfter > 0 && lines == options.ExitAfter {
+			if options.Hang {
+				time.Sleep(time.Hour)
+			}
+			return options.ExitCode
+		}
+		line := replacer.Replace(scanner.Text())
+		fmt.Fprintln(out, line)
+		id := ""
+		if strings.HasPrefix(line, "ID: ") {
+			id = line[4:]
+		} else if strings.HasPrefix(line, "Plot Name: ") && len(line) > 37 {
+			id = line[37:]
+		}
+		if len(id) > 0 {
+			tmpFile = filepath.Join(tempDir, fmt.Sprintf("plot-k32-%s.plot.table1.tmp", id))
+			ioutil.WriteFile(tmpFile, []byte("tmp"), 0600)
+		}
+		if m := chiaFinalFileRegex.FindStringSubmatch(line); m != nil {
+			ioutil.WriteFile(m[1], []byte("plot"), 0600)
+		} else if m := madmaxFinalFileRegex.FindStringSubmatch(line); m != nil {
+			ioutil.WriteFile(m[1], []byte("plot"), 0600)
+		}
+		time.Sleep(options.LineDelay)
+	}
+	if len(tmpFile) > 0 {
+		os.Remove(tmpFile)
+	}
+	return options.ExitCode
+}
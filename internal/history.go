@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"sort"
+	"time"
+)
+
+// phaseCount is the number of timed steps of a plot: the four phases and the final copy.
+const phaseCount = 5
+
+// phaseDurations returns how long each phase (1-4) and the final copy (5) took, indexed from 0.
+func (ap *ActivePlot) phaseDurations() (durations [phaseCount]time.Duration) {
+	for phase := 1; phase <= phaseCount; phase++ {
+		durations[phase-1] = ap.getPhaseTime(phase).Sub(ap.getPhaseTime(phase - 1))
+	}
+	return
+}
+
+// averagePhaseDurations averages the phase durations of the finished plots in archive accepted by match.
+// A nil match accepts every plot.
+func averagePhaseDurations(archive []*ActivePlot, match func(plot *ActivePlot) bool) (avg [phaseCount]time.Duration, count int) {
+	for _, plot := range archive {
+		if plot.State != PlotFinished || (match != nil && !match(plot)) {
+			continue
+		}
+		durations := plot.phaseDurations()
+		for i := range avg {
+			avg[i] += durations[i]
+		}
+		count++
+	}
+	if count > 0 {
+		for i := range avg {
+			avg[i] /= time.Duration(count)
+		}
+	}
+	return
+}
+
+// expectedPhaseDurations returns the historical phase durations of plots using the same temp
+// directory and plotter settings, falling back to the same temp directory, the same settings and
+// finally all plots while there is no history for them yet.
+func expectedPhaseDurations(archive []*ActivePlot, plot *ActivePlot) (avg [phaseCount]time.Duration, count int) {
+	for _, match := range []func(p *ActivePlot) bool{
+		func(p *ActivePlot) bool { return p.PlotDir == plot.PlotDir && sameSettings(p, plot) },
+		func(p *ActivePlot) bool { return p.PlotDir == plot.PlotDir },
+		func(p *ActivePlot) bool { return sameSettings(p, plot) },
+		nil,
+	} {
+		if avg, count = averagePhaseDurations(archive, match); count > 0 {
+			break
+		}
+	}
+	return
+}
+
+// sameSettings reports whether two plots were made with the same plotter settings.
+func sameSettings(a, b *ActivePlot) bool {
+	return a.PlotSize == b.PlotSize && a.Threads == b.Threads && a.Buffers == b.Buffers && a.BucketSize == b.BucketSize &&
+		(len(a.Tmp2Dir) > 0) == (len(b.Tmp2Dir) > 0)
+}
+
+// estimateRemaining returns how long an active plot is expected to take before it finishes, based on
+// the phase durations of similar plots.  It returns false when there is no history to go by.
+func estimateRemaining(archive []*ActivePlot, plot *ActivePlot, now time.Time) (time.Duration, bool) {
+	phase := plot.getCurrentPhase()
+	if phase < 1 || phase > phaseCount || plot.State == PlotError || plot.State == PlotKilled {
+		return 0, false
+	}
+	expected, count := expectedPhaseDurations(archive, plot)
+	if count == 0 {
+		return 0, false
+	}
+	remaining := expected[phase-1] - now.Sub(plot.getPhaseTime(phase-1))
+	if remaining < 0 {
+		remaining = 0 // running late, assume the phase is about to end
+	}
+	for i := phase; i < phaseCount; i++ {
+		remaining += expected[i]
+	}
+	return remaining, true
+}
+
+// plotSlots returns how many more plots fit in the available space, keeping the space the server
+// requires for a new plot.
+func plotSlots(available uint64, plotSize uint64) int {
+	if available < PLOT_SIZE || plotSize == 0 {
+		return 0
+	}
+	return int((available-PLOT_SIZE)/plotSize) + 1
+}
+
+// slotsFullTime returns when the slots are expected to be used up, by the plots being made (by their
+// expected finish time) and after them by a rate of finished plots per day.  It returns the zero time
+// if they are not expected to be used up.
+func slotsFullTime(slots int, finishes []time.Time, perDay float64, now time.Time) time.Time {
+	if slots <= 0 {
+		return now
+	}
+	sort.Slice(finishes, func(i, j int) bool { return finishes[i].Before(finishes[j]) })
+	if len(finishes) >= slots {
+		if finishes[slots-1].Before(now) {
+			return now
+		}
+		return finishes[slots-1]
+	}
+	if perDay <= 0 {
+		return time.Time{}
+	}
+	last := now
+	if len(finishes) > 0 && finishes[len(finishes)-1].After(now) {
+		last = finishes[len(finishes)-1]
+	}
+	return last.Add(time.Duration(float64(slots-len(finishes)) / perDay * float64(24*time.Hour)))
+}
+
+// diskForecast is the expected use of a destination directory.
+type diskForecast struct {
+	Slots    int         // plots which fit in the available space
+	Finishes []time.Time // expected finish time of the plots being made for it
+	PerDay   float64     // plots finished in it per day over the last week
+	FullAt   time.Time   // zero if not expected to be full
+}
+
+// forecastDiskFull forecasts when the destination directory dir is expected to be full, from the
+// plots being made for it and the plots finished in it over the last week.
+func forecastDiskFull(dir string, available uint64, actives []*ActivePlot, archive []*ActivePlot, now time.Time) diskForecast {
+	var forecast diskForecast
+	k := 0
+	for _, plot := range actives {
+		if plot.TargetDir != dir || plot.State == PlotError || plot.State == PlotKilled {
+			continue
+		}
+		k = plot.PlotSize
+		finish := now // plots without history could finish at any time
+		if remaining, ok := estimateRemaining(archive, plot, now); ok {
+			finish = now.Add(remaining)
+		}
+		forecast.Finishes = append(forecast.Finishes, finish)
+	}
+	var finished []*ActivePlot
+	for _, plot := range archive {
+		if plot.TargetDir == dir {
+			finished = append(finished, plot)
+			if len(forecast.Finishes) == 0 {
+				k = plot.PlotSize
+			}
+		}
+	}
+	forecast.Slots = plotSlots(available, plotFileSize(k))
+	forecast.PerDay = finishedThroughput(finished, now, 7*24*time.Hour).PlotsPerDay()
+	forecast.FullAt = slotsFullTime(forecast.Slots, forecast.Finishes, forecast.PerDay, now)
+	return forecast
+}
+
+// forecastFarmFull combines the forecasts of every destination directory, as the plots go to the
+// directories with space left once some are full.
+func forecastFarmFull(forecasts []diskForecast, now time.Time) time.Time {
+	var total diskForecast
+	for _, forecast := range forecasts {
+		total.Slots += forecast.Slots
+		total.Finishes = append(total.Finishes, forecast.Finishes...)
+		total.PerDay += forecast.PerDay
+	}
+	return slotsFullTime(total.Slots, total.Finishes, total.PerDay, now)
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateRemaining(t *testing.T) {
+	now := initialTime
+	past := func(plotDir string, threads int, phases ...time.Duration) *ActivePlot {
+		plot := &ActivePlot{State: PlotFinished, PlotDir: plotDir, Threads: threads, StartTime: now.Add(-48 * time.Hour)}
+		times := []*time.Time{&plot.Phase1Time, &plot.Phase2Time, &plot.Phase3Time, &plot.Phase4Time, &plot.EndTime}
+		at := plot.StartTime
+		for i, d := range phases {
+			at = at.Add(d)
+			*times[i] = at
+		}
+		return plot
+	}
+	h := time.Hour
+	archive := []*ActivePlot{
+		past("/tmp1", 4, 4*h, 2*h, 3*h, h, h),
+		past("/tmp1", 2, 8*h, 4*h, 6*h, 2*h, h),
+		past("/tmp2", 4, 6*h, 3*h, 3*h, h, h),
+	}
+
+	// same temp dir and settings: 4h+2h+3h+1h+1h, 1h into phase 2
+	plot := &ActivePlot{State: PlotRunning, PlotDir: "/tmp1", Threads: 4, Phase: "2/4", StartTime: now.Add(-5 * h), Phase1Time: now.Add(-h)}
+	if remaining, ok := estimateRemaining(archive, plot, now); !ok || remaining != 6*h {
+		t.Errorf("expected 6h, got %s %v", remaining, ok)
+	}
+	// running late in the copy
+	plot.Phase, plot.Phase2Time, plot.Phase3Time, plot.Phase4Time = "cp", now.Add(-h), now.Add(-h), now.Add(-2*h)
+	if remaining, ok := estimateRemaining(archive, plot, now); !ok || remaining != 0 {
+		t.Errorf("expected 0, got %s %v", remaining, ok)
+	}
+	// no plot with the same temp dir, same settings used
+	plot = &ActivePlot{State: PlotRunning, PlotDir: "/tmp3", Threads: 2, Phase: "1/4", StartTime: now}
+	if remaining, ok := estimateRemaining(archive, plot, now); !ok || remaining != 21*h {
+		t.Errorf("expected 21h, got %s %v", remaining, ok)
+	}
+	if _, ok := estimateRemaining(nil, plot, now); ok {
+		t.Error("no estimate expected without history")
+	}
+}
+
+func TestSlotsFullTime(t *testing.T) {
+	now := initialTime
+	plotSize := plotFileSize(32)
+	if slots := plotSlots(PLOT_SIZE-1, plotSize); slots != 0 {
+		t.Errorf("full disk: expected no slot, got %d", slots)
+	}
+	if slots := plotSlots(PLOT_SIZE+plotSize, plotSize); slots != 2 {
+		t.Errorf("expected 2 slots, got %d", slots)
+	}
+	if at := slotsFullTime(0, nil, 10, now); !at.Equal(now) {
+		t.Errorf("full disk: expected now, got %s", at)
+	}
+	// both slots used by plots being made
+	if at := slotsFullTime(2, []time.Time{now.Add(3 * time.Hour), now.Add(time.Hour)}, 0, now); !at.Equal(now.Add(3 * time.Hour)) {
+		t.Errorf("expected full when the second plot finishes, got %s", at)
+	}
+	// one plot being made, then 2 plots a day
+	if at := slotsFullTime(2, []time.Time{now.Add(time.Hour)}, 2, now); !at.Equal(now.Add(13 * time.Hour)) {
+		t.Errorf("expected full 12h after the first plot, got %s", at)
+	}
+	if at := slotsFullTime(2, nil, 0, now); !at.IsZero() {
+		t.Errorf("expected no forecast without plots, got %s", at)
+	}
+	// 1 + 3 slots, 1 plot a day in each directory
+	farm := forecastFarmFull([]diskForecast{{Slots: 1, PerDay: 1}, {Slots: 3, Finishes: []time.Time{now.Add(time.Hour)}, PerDay: 1}}, now)
+	if !farm.Equal(now.Add(time.Hour + 36*time.Hour)) {
+		t.Errorf("unexpected farm forecast: %s", farm)
+	}
+}
+
+func TestDiskFullEvents(t *testing.T) {
+	now := initialTime
+	var archive []*ActivePlot
+	for i := 1; i <= 7; i++ {
+		archive = append(archive, &ActivePlot{State: PlotFinished, TargetDir: "/dest2", StartTime: now.Add(-time.Duration(i) * 24 * time.Hour),
+			EndTime: now.Add(-time.Duration(i)*24*time.Hour + time.Hour)})
+	}
+	// /dest2 has 2 slots and 1 plot a day
+	available := map[string]uint64{"/dest1": PLOT_SIZE - 1, "/dest2": PLOT_SIZE + plotFileSize(32)}
+	types := func(window time.Duration) (result []string) {
+		for _, ev := range diskFullEvents(available, nil, archive, now, window) {
+			result = append(result, ev.Type+":"+ev.Subject)
+		}
+		return
+	}
+	if got := types(0); len(got) != 1 || got[0] != "disk_full:/dest1" {
+		t.Errorf("without forecast: unexpected events %v", got)
+	}
+	if got := types(24 * time.Hour); len(got) != 1 {
+		t.Errorf("24h: unexpected events %v", got)
+	}
+	if got := types(72 * time.Hour); len(got) != 3 || got[1] != "disk_full_soon:/dest2" || got[2] != "disk_full_soon:*" {
+		t.Errorf("72h: unexpected events %v", got)
+	}
+}
@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	HookOnStart       = "on_start"
+	HookOnPhaseChange = "on_phase_change"
+	HookOnFinish      = "on_finish"
+	HookOnError       = "on_error"
+	HookOnKill        = "on_kill"
+)
+
+const defaultHookTimeout = 60 * time.Second
+
+// HookConfig holds the shell commands run by the server when a plot changes state.
+type HookConfig struct {
+	OnStart       string
+	OnPhaseChange string
+	OnFinish      string
+	OnError       string
+	OnKill        string
+	Timeout       int // seconds, defaults to 60
+}
+
+func (hc *HookConfig) command(event string) string {
+	switch event {
+	case HookOnStart:
+		return hc.OnStart
+	case HookOnPhaseChange:
+		return hc.OnPhaseChange
+	case HookOnFinish:
+		return hc.OnFinish
+	case HookOnError:
+		return hc.OnError
+	case HookOnKill:
+		return hc.OnKill
+	}
+	return ""
+}
+
+func (hc *HookConfig) timeout() time.Duration {
+	if hc.Timeout > 0 {
+		return time.Duration(hc.Timeout) * time.Second
+	}
+	return defaultHookTimeout
+}
+
+// hookPayload is written as JSON to the hook's stdin.
+type hookPayload struct {
+	Event string
+	Plot  *ActivePlot
+}
+
+// queueHook runs the configured hook for the event, if any, after the hooks queued before it, so that
+// hooks see the changes of the plot in order without blocking the plotter.
+func (ap *ActivePlot) queueHook(event string) {
+	if len(ap.hooks.command(event)) == 0 {
+		return
+	}
+	hook := queuedHook{event: event, plot: ap.snapshot()}
+	ap.hookLock.Lock()
+	ap.hookQueue = append(ap.hookQueue, hook)
+	start := len(ap.hookQueue) == 1
+	ap.hookLock.Unlock()
+	if start {
+		go ap.runHooks()
+	}
+}
+
+// queuedHook is a hook waiting to run, with the plot as it was when the event happened.
+type queuedHook struct {
+	event string
+	plot  *ActivePlot
+}
+
+// runHooks runs the queued hooks one after the other, the running hook staying first in the queue.
+func (ap *ActivePlot) runHooks() {
+	ap.hookLock.Lock()
+	for len(ap.hookQueue) > 0 {
+		hook := ap.hookQueue[0]
+		ap.hookLock.Unlock()
+		ap.execHook(hook.event, hook.plot)
+		ap.hookLock.Lock()
+		ap.hookQueue = ap.hookQueue[1:]
+	}
+	ap.hookLock.Unlock()
+}
+
+// execHook runs the hook for the event with plot, a snapshot of ap, as payload and environment.  The hook
+// runs in its own process group, killed on timeout with every process the hook started.
+func (ap *ActivePlot) execHook(event string, plot *ActivePlot) {
+	command := ap.hooks.command(event)
+	if len(command) == 0 {
+		return
+	}
+	payload, err := json.Marshal(hookPayload{Event: event, Plot: plot})
+	if err != nil {
+		ap.appendLog(fmt.Sprintf("[%s] failed to encode hook payload: %s\n", event, err))
+		return
+	}
+
+	cmd := hookCommand(command)
+	cmd.Env = append(os.Environ(), plot.hookEnv(event)...)
+	cmd.Stdin = bytes.NewReader(payload)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	timedOut := false
+	if err = cmd.Start(); err == nil {
+		done := make(chan error, 1)
+		go func() {
+			done <- cmd.Wait()
+		}()
+		timer := time.NewTimer(ap.hooks.timeout())
+		select {
+		case err = <-done:
+		case <-timer.C:
+			timedOut = true
+			killHook(cmd)
+			err = <-done
+		}
+		timer.Stop()
+	}
+
+	scanner := bufio.NewScanner(&output)
+	for scanner.Scan() {
+		ap.appendLog(fmt.Sprintf("[%s] %s\n", event, scanner.Text()))
+	}
+	if timedOut {
+		ap.appendLog(fmt.Sprintf("[%s] hook timed out after %s\n", event, ap.hooks.timeout()))
+	} else if err != nil {
+		ap.appendLog(fmt.Sprintf("[%s] hook failed: %s\n", event, err))
+	}
+}
+
+func (ap *ActivePlot) hookEnv(event string) []string {
+	return []string{
+		"PLOTNG_EVENT=" + event,
+		"PLOTNG_PLOT_ID=" + ap.Id,
+		fmt.Sprintf("PLOTNG_PID=%d", ap.Pid),
+		"PLOTNG_STATE=" + stateString(ap.State),
+		"PLOTNG_PHASE=" + ap.Phase,
+		"PLOTNG_PROGRESS=" + ap.Progress,
+		"PLOTNG_PLOT_DIR=" + ap.PlotDir,
+		"PLOTNG_TARGET_DIR=" + ap.TargetDir,
+		"PLOTNG_TMP2_DIR=" + ap.Tmp2Dir,
+		fmt.Sprintf("PLOTNG_PLOT_SIZE=%d", ap.PlotSize),
+		"PLOTNG_START_TIME=" + ap.StartTime.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitForHooks waits for the hooks queued by plot to run and returns the plot log.
+func waitForHooks(t *testing.T, plot *ActivePlot) string {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		plot.hookLock.Lock()
+		pending := len(plot.hookQueue)
+		plot.hookLock.Unlock()
+		if pending == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d hooks still queued", pending)
+		}
+	}
+	plot.lock.RLock()
+	defer plot.lock.RUnlock()
+	return strings.Join(plot.Tail, "")
+}
+
+func TestQueueHookCapturesOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test uses sh")
+	}
+	plot := &ActivePlot{
+		Id:      "abc",
+		PlotDir: "/tmp/plot",
+		hooks: HookConfig{
+			OnFinish: `echo "$PLOTNG_EVENT $PLOTNG_PLOT_ID $PLOTNG_PLOT_DIR"; grep -o '"Event":"on_finish"'`,
+		},
+	}
+	plot.queueHook(HookOnFinish)
+
+	log := waitForHooks(t, plot)
+	if !strings.Contains(log, "[on_finish] on_finish abc /tmp/plot\n") {
+		t.Errorf("environment not passed to hook, log: %q", log)
+	}
+	if !strings.Contains(log, `[on_finish] "Event":"on_finish"`) {
+		t.Errorf("payload not passed to hook, log: %q", log)
+	}
+}
+
+func TestQueueHookTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test uses sh")
+	}
+	plot := &ActivePlot{
+		hooks: HookConfig{
+			// the shell forks sleep, which keeps the output of the hook open
+			OnKill:  "sleep 5; echo done",
+			Timeout: 1,
+		},
+	}
+	start := time.Now()
+	plot.queueHook(HookOnKill)
+
+	log := waitForHooks(t, plot)
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("hook killed after %s", elapsed)
+	}
+	if !strings.Contains(log, "hook timed out") || strings.Contains(log, "done") {
+		t.Errorf("expected timeout, log: %q", log)
+	}
+}
+
+func TestQueueHookKeepsOrder(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test uses sh")
+	}
+	plot := &ActivePlot{
+		hooks: HookConfig{
+			OnPhaseChange: `[ "$PLOTNG_PHASE" = 1/4 ] && sleep 0.3; echo "$PLOTNG_PHASE"`,
+		},
+	}
+	for _, phase := range []string{"1/4", "2/4", "3/4"} {
+		plot.lock.Lock()
+		plot.Phase = phase
+		plot.lock.Unlock()
+		plot.queueHook(HookOnPhaseChange)
+	}
+	log := waitForHooks(t, plot)
+	if expected := "[on_phase_change] 1/4\n[on_phase_change] 2/4\n[on_phase_change] 3/4\n"; log != expected {
+		t.Errorf("expected hooks in order, log: %q", log)
+	}
+}
+
+func TestQueueHookWithoutCommand(t *testing.T) {
+	plot := &ActivePlot{}
+	plot.queueHook(HookOnStart)
+	if plot.hookQueue != nil || len(plot.Tail) != 0 {
+		t.Errorf("unexpected log: %q", plot.Tail)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build windows
+// +build windows
+
+package internal
+
+import "os/exec"
+
+func hookCommand(command string) *exec.Cmd {
+	return exec.Command("cmd", "/C", command)
+}
+
+// killHook kills the hook, the commands it started are left running.
+func killHook(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
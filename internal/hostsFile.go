@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultPort = "8484"
+
+// HostEntry is a plotter in the hosts file.
+type HostEntry struct {
+	Name    string // display name, defaults to Address
+	Address string // host name or IP with optional port number
+	Tags    []string
+	Token   string // sent to plotters configured with an AuthToken
+}
+
+func (he *HostEntry) normalize() {
+	he.Address = strings.TrimSpace(he.Address)
+	if strings.Index(he.Address, ":") < 0 {
+		he.Address += ":" + defaultPort
+	}
+	he.Name = strings.TrimSpace(he.Name)
+	if len(he.Name) == 0 {
+		he.Name = he.Address
+	}
+}
+
+// HostsFile is a JSON file holding a list of HostEntry, which is reloaded when it changes.
+type HostsFile struct {
+	Path    string
+	LastMod time.Time
+}
+
+// Load reads the hosts file if it changed since the last Load or Save.
+func (hf *HostsFile) Load() (hosts []HostEntry, changed bool, err error) {
+	fs, err := os.Stat(hf.Path)
+	if err != nil {
+		return nil, false, err
+	}
+	if fs.ModTime() == hf.LastMod {
+		return nil, false, nil
+	}
+	data, err := ioutil.ReadFile(hf.Path)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := json.Unmarshal(data, &hosts); err != nil {
+		return nil, false, err
+	}
+	for i := range hosts {
+		hosts[i].normalize()
+	}
+	hf.LastMod = fs.ModTime()
+	return hosts, true, nil
+}
+
+// Save writes the hosts to the file.
+func (hf *HostsFile) Save(hosts []HostEntry) error {
+	data, err := json.MarshalIndent(hosts, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(hf.Path, data, 0600); err != nil {
+		return err
+	}
+	if fs, err := os.Stat(hf.Path); err == nil {
+		hf.LastMod = fs.ModTime()
+	}
+	return nil
+}
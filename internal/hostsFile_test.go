@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHostsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plotng-hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "hosts.json")
+	if err := ioutil.WriteFile(path, []byte(`[{"Name": "plotter1", "Address": "10.0.0.1:8485", "Tags": ["rack1"]}, {"Address": " plotter2 "}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	hf := &HostsFile{Path: path}
+	hosts, changed, err := hf.Load()
+	if err != nil || !changed {
+		t.Fatalf("load failed: changed=%v err=%v", changed, err)
+	}
+	if len(hosts) != 2 || hosts[0].Name != "plotter1" || hosts[0].Address != "10.0.0.1:8485" {
+		t.Fatalf("unexpected hosts: %+v", hosts)
+	}
+	if hosts[1].Name != "plotter2:8484" || hosts[1].Address != "plotter2:8484" {
+		t.Errorf("host not normalized: %+v", hosts[1])
+	}
+	if _, changed, err := hf.Load(); err != nil || changed {
+		t.Errorf("unchanged file reloaded: changed=%v err=%v", changed, err)
+	}
+
+	hosts = append(hosts[:1], HostEntry{Name: "plotter3", Address: "plotter3:8484", Token: "secret"})
+	if err := hf.Save(hosts); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, _, err := (&HostsFile{Path: path}).Load()
+	if err != nil || len(reloaded) != 2 || reloaded[1].Token != "secret" {
+		t.Errorf("unexpected saved hosts: %+v err=%v", reloaded, err)
+	}
+}
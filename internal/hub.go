@@ -0,0 +1,483 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Hub polls every plotng-server of a farm and serves their combined state, so clients only need to
+// reach the hub.  Archived plots are kept in a state file, so they survive plotter restarts.
+//
+// Like a plotter, the hub versions its state with the sequence of its events: each plot carries the
+// version it last changed at, so clients passing since get a delta of every plotter.
+type Hub struct {
+	HostsFile       string // JSON list of HostEntry, reloaded when it changes
+	StatePath       string // file keeping the archive of every plotter
+	AuthToken       string // required from clients when set
+	ArchiveMaxCount int    // archived plots kept per plotter, 0 for no limit
+	ArchiveMaxDays  int    // days to keep archived plots, by their end time, 0 for no limit
+
+	hosts         map[string]*hubHost
+	archive       map[string][]*ActivePlot
+	archiveCutoff map[string]time.Time // archived plots of a plotter which ended before it were pruned
+	resets        map[string]uint64    // version at which the plots of a plotter were last replaced
+	hostsFile     *HostsFile
+	events        *eventHub
+	epoch         int64
+	lock          sync.RWMutex
+}
+
+type hubHost struct {
+	HostEntry
+	msg    *Msg
+	status hostStatus
+	cancel context.CancelFunc
+}
+
+func (hub *Hub) ProcessLoop(hostList string, address string, port int) {
+	gob.Register(Msg{})
+	gob.Register(ActivePlot{})
+
+	hub.hosts = map[string]*hubHost{}
+	hub.archive = map[string][]*ActivePlot{}
+	hub.archiveCutoff = map[string]time.Time{}
+	hub.resets = map[string]uint64{}
+	hub.events = newEventHub()
+	hub.epoch = time.Now().UnixNano()
+	if err := hub.loadState(); err != nil {
+		log.Printf("Failed to load hub state [%s]: %s", hub.StatePath, err)
+	}
+
+	for _, host := range strings.Split(hostList, ",") {
+		if host = strings.TrimSpace(host); len(host) > 0 {
+			hub.addHost(HostEntry{Address: host})
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if len(hub.HostsFile) > 0 {
+		hub.hostsFile = &HostsFile{Path: hub.HostsFile}
+		go hub.watchHostsFile(ctx)
+	}
+
+	if err := http.ListenAndServe(fmt.Sprintf("%s:%d", address, port), hub); err != nil {
+		log.Fatalf("Failed to start webserver: %s", err)
+	}
+}
+
+// addHost starts polling a plotter, unless a plotter with the same name is already polled.
+func (hub *Hub) addHost(entry HostEntry) *hubHost {
+	entry.normalize()
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	if _, ok := hub.hosts[entry.Name]; ok {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	host := &hubHost{HostEntry: entry, cancel: cancel}
+	hub.hosts[entry.Name] = host
+	watcher := &hostWatcher{
+		host:  entry.Address,
+		token: entry.Token,
+		update: func(msg *Msg, status hostStatus) {
+			if ctx.Err() == nil {
+				hub.updateHost(host, msg, status)
+			}
+		},
+	}
+	go watcher.run(ctx)
+	hub.resets[entry.Name] = hub.events.publish(PlotEvent{Kind: PlotEventServer})
+	log.Printf("Polling plotter %s (%s)", entry.Name, entry.Address)
+	return host
+}
+
+func (hub *Hub) removeHost(name string) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	if host, ok := hub.hosts[name]; ok {
+		host.cancel()
+		delete(hub.hosts, name)
+		hub.resets[name] = hub.events.publish(PlotEvent{Kind: PlotEventServer})
+		log.Printf("Stopped polling plotter %s", name)
+	}
+}
+
+// watchHostsFile adds and removes plotters as the hosts file changes, until ctx is done.
+func (hub *Hub) watchHostsFile(ctx context.Context) {
+	fromFile := map[string]HostEntry{}
+	ticker := time.NewTicker(hostsFileCheckInterval)
+	defer ticker.Stop()
+	for {
+		entries, changed, err := hub.hostsFile.Load()
+		if err != nil {
+			log.Printf("Failed to load hosts file [%s]: %s", hub.hostsFile.Path, err)
+		} else if changed {
+			wanted := map[string]HostEntry{}
+			for _, entry := range entries {
+				wanted[entry.Name] = entry
+			}
+			for name, entry := range fromFile {
+				if w, ok := wanted[name]; !ok || w.Address != entry.Address || w.Token != entry.Token {
+					hub.removeHost(name)
+					delete(fromFile, name)
+				}
+			}
+			for _, entry := range entries {
+				if _, ok := fromFile[entry.Name]; !ok && hub.addHost(entry) != nil {
+					fromFile[entry.Name] = entry
+				}
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// updateHost stores the latest Msg of a plotter and adds its new archived plots to the hub archive.
+// Clients are only woken up when the plotter changed, not after every poll.
+func (hub *Hub) updateHost(host *hubHost, msg *Msg, status hostStatus) {
+	hub.lock.Lock()
+	changed := fmt.Sprint(status.Err) != fmt.Sprint(host.status.Err)
+	host.status = status
+	reset, added := false, false
+	var plots []*ActivePlot // plots changed by the update
+	if msg != nil {
+		reset = msg.Version == 0 || (host.msg != nil && msg.Epoch != host.msg.Epoch)
+		changed = changed || reset || host.msg == nil || msg.Version != host.msg.Version
+		var old []*ActivePlot
+		if host.msg != nil {
+			old = host.msg.Actives
+		}
+		plots = newPlots(old, msg.Actives)
+		host.msg = msg
+		var archived []*ActivePlot
+		archived, added = hub.mergeArchive(host.Name, msg.Archived)
+		plots = append(plots, archived...)
+	}
+	pruned := added && hub.pruneArchive(time.Now())
+	if changed || pruned || len(plots) > 0 {
+		seq := hub.events.publish(PlotEvent{Kind: PlotEventServer})
+		for _, plot := range plots {
+			atomic.StoreUint64(&plot.version, seq)
+		}
+		if reset {
+			hub.resets[host.Name] = seq
+		}
+	}
+	hub.lock.Unlock()
+
+	if added {
+		if err := hub.saveState(); err != nil {
+			log.Printf("Failed to save hub state [%s]: %s", hub.StatePath, err)
+		}
+	}
+}
+
+// newPlots returns the plots which are not in old.  A Msg applying a delta keeps the plots which did
+// not change, so a plot is new unless old has the very same one.
+func newPlots(old []*ActivePlot, plots []*ActivePlot) []*ActivePlot {
+	known := map[*ActivePlot]bool{}
+	for _, plot := range old {
+		known[plot] = true
+	}
+	var changed []*ActivePlot
+	for _, plot := range plots {
+		if !known[plot] {
+			changed = append(changed, plot)
+		}
+	}
+	return changed
+}
+
+// mergeArchive adds the plots archived by a plotter to the hub archive, returning the plots added or
+// updated, and whether any was added.  Must be called with the hub lock held.
+func (hub *Hub) mergeArchive(name string, plots []*ActivePlot) (changed []*ActivePlot, added bool) {
+	known := map[int64]int{}
+	for i, plot := range hub.archive[name] {
+		known[plot.PlotId] = i
+	}
+	for _, plot := range plots {
+		if plot.EndTime.Before(hub.archiveCutoff[name]) {
+			continue
+		}
+		if i, ok := known[plot.PlotId]; ok {
+			if hub.archive[name][i] != plot {
+				hub.archive[name][i] = plot
+				changed = append(changed, plot)
+			}
+			continue
+		}
+		known[plot.PlotId] = len(hub.archive[name])
+		hub.archive[name] = append(hub.archive[name], plot)
+		changed = append(changed, plot)
+		added = true
+	}
+	return changed, added
+}
+
+// pruneArchive applies the ArchiveMaxCount and ArchiveMaxDays limits to the archive of every plotter,
+// returning true if plots were removed.  Must be called with the hub lock held.
+func (hub *Hub) pruneArchive(now time.Time) bool {
+	pruned := false
+	for name, archive := range hub.archive {
+		count := len(archive)
+		kept, cutoff := pruneArchive(archive, hub.ArchiveMaxCount, hub.ArchiveMaxDays, now)
+		if len(kept) == count {
+			continue
+		}
+		// clients drop the plots which ended before the cutoff when applying the next delta
+		hub.archiveCutoff[name] = cutoff
+		if len(kept) > 0 {
+			hub.archive[name] = kept
+		} else {
+			delete(hub.archive, name)
+		}
+		pruned = true
+	}
+	return pruned
+}
+
+func (hub *Hub) loadState() error {
+	if len(hub.StatePath) == 0 {
+		return nil
+	}
+	f, err := os.Open(hub.StatePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	archive := map[string][]*ActivePlot{}
+	if err := gob.NewDecoder(f).Decode(&archive); err != nil {
+		return err
+	}
+	hub.lock.Lock()
+	hub.archive = archive
+	hub.pruneArchive(time.Now())
+	hub.lock.Unlock()
+	return nil
+}
+
+func (hub *Hub) saveState() error {
+	if len(hub.StatePath) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	hub.lock.RLock()
+	err := gob.NewEncoder(&buf).Encode(hub.archive)
+	hub.lock.RUnlock()
+	if err != nil {
+		return err
+	}
+	tmp := hub.StatePath + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, hub.StatePath)
+}
+
+func (hub *Hub) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	log.Printf("New query: %s -  %s", req.Method, req.URL.String())
+	if strings.HasPrefix(req.URL.Path, "/ui") {
+		serveWebUI(resp, req)
+		return
+	}
+	if !checkToken(req, hub.AuthToken) {
+		http.Error(resp, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if wantsWebUI(req) {
+		http.Redirect(resp, req, "/ui/", http.StatusFound)
+		return
+	}
+	if strings.HasPrefix(req.URL.Path, "/hosts/") {
+		// the plotters trust the hub with their token, so the hub guards changes and the settings of the
+		// plotters with its own
+		if (req.Method != "GET" || proxiesSettings(req.URL.Path)) && !canModify(resp, hub.AuthToken) {
+			return
+		}
+		hub.proxy(resp, req)
+		return
+	}
+	if req.Method != "GET" {
+		http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if req.URL.Path == "/api/state" {
+		hub.lock.RLock()
+		msg := hub.makeMsg(0)
+		hub.lock.RUnlock()
+		writeJSON(resp, msg)
+		return
+	}
+	if req.URL.Path == "/archive" || req.URL.Path == "/archive/export" {
+		collect := func(filter archiveFilter) []archiveRecord {
+			hub.lock.RLock()
+			defer hub.lock.RUnlock()
+			return exportArchive(hub.archive, filter)
+		}
+		if req.URL.Path == "/archive" {
+			serveArchivePage(resp, req, collect)
+		} else {
+			serveArchiveExport(resp, req, collect)
+		}
+		return
+	}
+	hub.serveState(resp, req)
+}
+
+// serveState writes the combined Msg of the plotters, see Server.serveState.  A delta holds a delta Msg
+// for every plotter, or its full Msg when its plots were replaced since, eg. after a plotter restart.
+func (hub *Hub) serveState(resp http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	since, _ := strconv.ParseUint(query.Get("since"), 10, 64)
+	epoch, _ := strconv.ParseInt(query.Get("epoch"), 10, 64)
+	wait, _ := strconv.Atoi(query.Get("wait"))
+	if epoch != hub.epoch || since > hub.events.currentSeq() {
+		since = 0
+	}
+	if since > 0 && wait > 0 {
+		start := time.Now()
+		hub.events.waitForChange(req.Context(), since, time.Duration(wait)*time.Second)
+		resp.Header().Set(waitHeader, strconv.FormatInt(int64(time.Since(start)/time.Millisecond), 10))
+	}
+
+	hub.lock.RLock()
+	msg := hub.makeMsg(since)
+	hub.lock.RUnlock()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(msg); err == nil {
+		resp.WriteHeader(http.StatusOK)
+		resp.Write(buf.Bytes())
+	} else {
+		resp.WriteHeader(http.StatusInternalServerError)
+		log.Printf("Failed to encode message: %s", err)
+	}
+}
+
+// makeMsg builds the combined Msg of the plotters, only including the plots changed after since unless
+// it is 0.  Must be called with the hub lock held.
+func (hub *Hub) makeMsg(since uint64) *Msg {
+	msg := &Msg{
+		Hosts:   map[string]*Msg{},
+		Version: hub.events.currentSeq(),
+		Epoch:   hub.epoch,
+		Delta:   since > 0,
+	}
+	reachable := 0
+	for name, host := range hub.hosts {
+		hostMsg := hub.makeHostMsg(name, since)
+		hostMsg.LastSeen = host.status.LastSeen
+		hostMsg.Latency = host.status.Latency
+		if host.msg != nil {
+			hostMsg.Actives = plotsSince(host.msg.Actives, hostMsg.Delta, since)
+			hostMsg.TempDirs = host.msg.TempDirs
+			hostMsg.TargetDirs = host.msg.TargetDirs
+			hostMsg.Status = host.msg.Status
+		}
+		if host.status.Err != nil {
+			hostMsg.Status = fmt.Sprintf("unreachable since %s: %s", host.status.LastSeen.Format("2006-01-02 15:04"), host.status.Err)
+		} else if host.msg != nil {
+			reachable++
+		}
+		msg.Hosts[name] = hostMsg
+	}
+	// plotters removed from the hub keep their archive
+	for name := range hub.archive {
+		if _, ok := msg.Hosts[name]; !ok {
+			msg.Hosts[name] = hub.makeHostMsg(name, since)
+			msg.Hosts[name].Status = "no longer polled"
+		}
+	}
+	msg.Status = fmt.Sprintf("Hub: %d of %d plotters reachable", reachable, len(hub.hosts))
+	return msg
+}
+
+// makeHostMsg builds the Msg of a plotter with its archived plots changed after since, or every one of
+// them if since is 0 or the plots of the plotter were replaced since.  Must be called with the hub lock
+// held.
+func (hub *Hub) makeHostMsg(name string, since uint64) *Msg {
+	hostMsg := &Msg{
+		Delta:         since > 0 && hub.resets[name] <= since,
+		ArchiveCutoff: hub.archiveCutoff[name],
+	}
+	hostMsg.Archived = plotsSince(hub.archive[name], hostMsg.Delta, since)
+	return hostMsg
+}
+
+// plotsSince returns a copy of the plots, only those changed after since for a delta.  The copy is
+// encoded after the hub lock is released, while the archive keeps changing.
+func plotsSince(plots []*ActivePlot, delta bool, since uint64) []*ActivePlot {
+	var changed []*ActivePlot
+	for _, plot := range plots {
+		if !delta || plot.getVersion() > since {
+			changed = append(changed, plot)
+		}
+	}
+	return changed
+}
+
+// proxiesSettings reports whether a /hosts/{name}/... path reaches the configuration or diagnostics of
+// the plotter, which the token of the plotter would otherwise open to any client of the hub.
+func proxiesSettings(path string) bool {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/hosts/"), "/", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	switch strings.Trim(parts[1], "/") {
+	case "api/config", "api/diagnostics":
+		return true
+	}
+	return false
+}
+
+// proxy forwards /hosts/{name}/... to the plotter, eg. /hosts/plotter1/plots/{id}/events
+func (hub *Hub) proxy(resp http.ResponseWriter, req *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/hosts/"), "/", 2)
+	name, _ := url.PathUnescape(parts[0])
+	hub.lock.RLock()
+	host, ok := hub.hosts[name]
+	hub.lock.RUnlock()
+	if !ok || len(parts) != 2 {
+		http.NotFound(resp, req)
+		return
+	}
+	target := &url.URL{Scheme: "http", Host: host.Address}
+	proxy := &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.URL.Scheme = target.Scheme
+			r.URL.Host = target.Host
+			r.URL.Path = "/" + parts[1]
+			r.URL.RawPath = ""
+			r.Host = target.Host
+			r.RequestURI = ""
+			query := r.URL.Query()
+			query.Del("token")
+			r.URL.RawQuery = query.Encode()
+			r.Header.Del("Authorization")
+			if len(host.Token) > 0 {
+				r.Header.Set("Authorization", "Bearer "+host.Token)
+			}
+		},
+		FlushInterval: -1, // log and event streams
+	}
+	proxy.ServeHTTP(resp, req)
+}
@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHub(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plotng-hub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	svr := &Server{
+		config: &PlotConfig{},
+		active: make(map[int64]*ActivePlot),
+		events: newEventHub(),
+		epoch:  1,
+	}
+	svr.active[2] = &ActivePlot{PlotId: 2, State: PlotRunning, Phase: "1/4", events: svr.events}
+	svr.active[2].publishState()
+	svr.archive = []*ActivePlot{{PlotId: 1, State: PlotFinished, events: svr.events}}
+	svr.archive[0].publishState()
+	plotter := httptest.NewServer(svr)
+	defer plotter.Close()
+
+	hub := &Hub{
+		StatePath:     filepath.Join(dir, "hub.state"),
+		hosts:         map[string]*hubHost{},
+		archive:       map[string][]*ActivePlot{},
+		archiveCutoff: map[string]time.Time{},
+		resets:        map[string]uint64{},
+		events:        newEventHub(),
+		epoch:         1,
+	}
+	hub.addHost(HostEntry{Name: "plotter1", Address: strings.TrimPrefix(plotter.URL, "http://")})
+	defer hub.removeHost("plotter1")
+	waitFor := func(cond func(msg *Msg) bool) *Msg {
+		t.Helper()
+		for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+			if msg := getMsg(t, hub, "/").Hosts["plotter1"]; msg != nil && cond(msg) {
+				return msg
+			}
+		}
+		t.Fatal("hub not updated")
+		return nil
+	}
+
+	msg := waitFor(func(msg *Msg) bool { return len(msg.Actives) == 1 })
+	if len(msg.Archived) != 1 || msg.LastSeen.IsZero() {
+		t.Fatalf("unexpected plotter msg: %+v", msg)
+	}
+
+	// a delta only has the plots changed since, the plotter being polled at once
+	full := getMsg(t, hub, "/")
+	svr.lock.Lock()
+	svr.active[3] = &ActivePlot{PlotId: 3, State: PlotRunning, Phase: "1/4", events: svr.events}
+	svr.lock.Unlock()
+	svr.active[3].publishState()
+	delta := getMsg(t, hub, fmt.Sprintf("/?since=%d&epoch=1&wait=5", full.Version))
+	if msg := delta.Hosts["plotter1"]; !delta.Delta || !msg.Delta || len(msg.Actives) != 1 || msg.Actives[0].PlotId != 3 || len(msg.Archived) != 0 {
+		t.Fatalf("unexpected delta: %+v", msg)
+	}
+	if msg := full.apply(delta).Hosts["plotter1"]; len(msg.Actives) != 2 || len(msg.Archived) != 1 {
+		t.Errorf("unexpected merged msg: %+v", msg)
+	}
+
+	// the archive is kept when the plotter restarts
+	hub.lock.RLock()
+	host := hub.hosts["plotter1"]
+	hub.lock.RUnlock()
+	hub.updateHost(host, &Msg{Epoch: 2}, hostStatus{LastSeen: time.Now()})
+	msg = getMsg(t, hub, "/").Hosts["plotter1"]
+	if len(msg.Actives) != 0 || len(msg.Archived) != 1 {
+		t.Errorf("archive lost: %+v", msg)
+	}
+	reloaded := &Hub{StatePath: hub.StatePath}
+	if err := reloaded.loadState(); err != nil || len(reloaded.archive["plotter1"]) != 1 {
+		t.Errorf("archive not saved: %+v err=%v", reloaded.archive, err)
+	}
+
+	// requests to /hosts/{name}/ are passed to the plotter
+	resp := httptest.NewRecorder()
+	hub.ServeHTTP(resp, httptest.NewRequest("GET", "/hosts/plotter1/", nil))
+	var proxied Msg
+	if err := gob.NewDecoder(resp.Body).Decode(&proxied); err != nil || proxied.Epoch != 1 {
+		t.Errorf("unexpected proxied msg: %+v err=%v", proxied, err)
+	}
+	// the configuration of the plotters is only proxied once the hub has a token
+	resp = httptest.NewRecorder()
+	hub.ServeHTTP(resp, httptest.NewRequest("GET", "/hosts/plotter1/api/config", nil))
+	if resp.Code != 403 {
+		t.Errorf("plotter config without a hub token: expected 403, got %d", resp.Code)
+	}
+	resp = httptest.NewRecorder()
+	hub.ServeHTTP(resp, httptest.NewRequest("GET", "/hosts/unknown/", nil))
+	if resp.Code != 404 {
+		t.Errorf("unknown plotter: expected 404, got %d", resp.Code)
+	}
+}
+
+func TestHubArchiveRetention(t *testing.T) {
+	now := time.Now()
+	hub := &Hub{
+		ArchiveMaxCount: 2,
+		archive:         map[string][]*ActivePlot{},
+		archiveCutoff:   map[string]time.Time{},
+		resets:          map[string]uint64{},
+	}
+	var plots []*ActivePlot
+	for i := 1; i <= 3; i++ {
+		plots = append(plots, &ActivePlot{PlotId: int64(i), State: PlotFinished, EndTime: now.Add(time.Duration(i) * time.Hour)})
+	}
+	if _, added := hub.mergeArchive("plotter1", plots); !added || !hub.pruneArchive(now) {
+		t.Fatal("expected the archive to be pruned")
+	}
+	if len(hub.archive["plotter1"]) != 2 || !hub.archiveCutoff["plotter1"].Equal(plots[1].EndTime) {
+		t.Errorf("unexpected archive: %+v cutoff %s", hub.archive["plotter1"], hub.archiveCutoff["plotter1"])
+	}
+	// the plotter keeps reporting the pruned plot
+	if changed, added := hub.mergeArchive("plotter1", plots); added || len(changed) != 0 {
+		t.Errorf("pruned plot added again: %+v", changed)
+	}
+	if msg := hub.makeMsg(0).Hosts["plotter1"]; len(msg.Archived) != 2 || msg.ArchiveCutoff.IsZero() {
+		t.Errorf("unexpected msg: %+v", msg)
+	}
+}
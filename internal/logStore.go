@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LogStore keeps the full log of every plot in Dir.  The log of a running plot is plain text, and is
+// compressed once the plot ends.  Old logs are removed according to MaxCount and MaxDays, and the log of
+// a plot stops growing at MaxSize.
+type LogStore struct {
+	Dir      string
+	MaxCount int   // number of compressed logs kept, 0 keeps all
+	MaxDays  int   // days compressed logs are kept, 0 keeps all
+	MaxSize  int64 // bytes logged per plot, 0 for no limit
+}
+
+const logFilePrefix = "plot_"
+
+const defaultPlotLogMaxMB = 100
+
+func newLogStore(config *Config, configPath string) *LogStore {
+	dir := config.PlotLogDir
+	if len(dir) == 0 {
+		dir = filepath.Join(filepath.Dir(configPath), "plotng-logs")
+	}
+	maxMB := config.PlotLogMaxMB
+	if maxMB <= 0 {
+		maxMB = defaultPlotLogMaxMB
+	}
+	return &LogStore{
+		Dir:      dir,
+		MaxCount: config.PlotLogMaxCount,
+		MaxDays:  config.PlotLogMaxDays,
+		MaxSize:  int64(maxMB) * int64(MB),
+	}
+}
+
+func (ls *LogStore) path(plotId int64) string {
+	return filepath.Join(ls.Dir, fmt.Sprintf("%s%d.log", logFilePrefix, plotId))
+}
+
+// Create creates the plain text log of a new plot.
+func (ls *LogStore) Create(plotId int64) (*os.File, error) {
+	if err := os.MkdirAll(ls.Dir, 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(ls.path(plotId))
+}
+
+// Compress replaces the plain text log of a plot by its compressed version.
+func (ls *LogStore) Compress(plotId int64) error {
+	in, err := os.Open(ls.path(plotId))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmpPath := ls.path(plotId) + ".gz.tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, ls.path(plotId)+".gz")
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	in.Close()
+	return os.Remove(ls.path(plotId))
+}
+
+// Open returns the uncompressed log of a plot, whether it is still running or not.
+func (ls *LogStore) Open(plotId int64) (io.ReadCloser, error) {
+	if f, err := os.Open(ls.path(plotId)); err == nil {
+		return f, nil
+	}
+	f, err := os.Open(ls.path(plotId) + ".gz")
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &gzipFile{Reader: zr, file: f}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (gf *gzipFile) Close() error {
+	gf.Reader.Close()
+	return gf.file.Close()
+}
+
+// Prune removes compressed logs beyond MaxCount or older than MaxDays.
+func (ls *LogStore) Prune(now time.Time) {
+	if ls.MaxCount <= 0 && ls.MaxDays <= 0 {
+		return
+	}
+	fileList, err := ioutil.ReadDir(ls.Dir)
+	if err != nil {
+		return
+	}
+	var logs []os.FileInfo
+	for _, file := range fileList {
+		if strings.HasPrefix(file.Name(), logFilePrefix) && strings.HasSuffix(file.Name(), ".log.gz") {
+			logs = append(logs, file)
+		}
+	}
+	sort.Slice(logs, func(i, j int) bool {
+		return logs[i].ModTime().After(logs[j].ModTime())
+	})
+	for i, file := range logs {
+		expired := ls.MaxDays > 0 && now.Sub(file.ModTime()) > time.Duration(ls.MaxDays)*24*time.Hour
+		if expired || (ls.MaxCount > 0 && i >= ls.MaxCount) {
+			fullPath := filepath.Join(ls.Dir, file.Name())
+			if err := os.Remove(fullPath); err != nil {
+				log.Printf("Failed to delete log file: %s\n", fullPath)
+			}
+		}
+	}
+}
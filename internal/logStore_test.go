@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLogStoreCompressAndPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plotng")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := &LogStore{Dir: filepath.Join(dir, "logs"), MaxCount: 2}
+
+	for plotId := int64(1); plotId <= 3; plotId++ {
+		f, err := store.Create(plotId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString("line 1\nline 2\n")
+		f.Close()
+		if err := store.Compress(plotId); err != nil {
+			t.Fatal(err)
+		}
+		modTime := initialTime.Add(time.Duration(plotId) * time.Hour)
+		os.Chtimes(store.path(plotId)+".gz", modTime, modTime)
+	}
+	store.Prune(time.Now())
+
+	if _, err := store.Open(1); err == nil {
+		t.Error("oldest log should have been pruned")
+	}
+	in, err := store.Open(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if data, _ := ioutil.ReadAll(in); string(data) != "line 1\nline 2\n" {
+		t.Errorf("unexpected log: %q", data)
+	}
+}
+
+func TestServePlotLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plotng")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	plot := &ActivePlot{PlotId: 42, Id: "abc", State: PlotFinished, logStore: &LogStore{Dir: dir}}
+	plot.openFullLog()
+	plot.appendLog("ID: abc\n")
+	plot.appendLog("Starting phase 1/4\n")
+	plot.closeLog()
+	svr := &Server{archive: []*ActivePlot{plot}}
+
+	for url, expected := range map[string]string{
+		"/plots/abc/log":           "ID: abc\nStarting phase 1/4\n",
+		"/plots/42/log?offset=8":   "Starting phase 1/4\n",
+		"/plots/abc/log?offset=99": "",
+	} {
+		resp := httptest.NewRecorder()
+		svr.ServeHTTP(resp, httptest.NewRequest("GET", url, nil))
+		if resp.Code != 200 || resp.Body.String() != expected {
+			t.Errorf("%s: unexpected response %d %q", url, resp.Code, resp.Body.String())
+		}
+	}
+
+	resp := httptest.NewRecorder()
+	svr.ServeHTTP(resp, httptest.NewRequest("GET", "/plots/unknown/log", nil))
+	if resp.Code != 404 {
+		t.Errorf("unexpected response for unknown plot: %d", resp.Code)
+	}
+}
+
+func TestPlotLogMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	plot := &ActivePlot{PlotId: 42, logStore: &LogStore{Dir: dir, MaxSize: 20}}
+	plot.openFullLog()
+	for _, line := range []string{"Computing table 1\n", "Computing table 2\n", "Computing table 3\n"} {
+		plot.appendLog(line)
+	}
+	plot.closeLog()
+
+	in, err := plot.logStore.Open(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if data, _ := ioutil.ReadAll(in); string(data) != "Computing table 1\nplotng: log truncated at 20 bytes\n" {
+		t.Errorf("unexpected log: %q", data)
+	}
+	if len(plot.Tail) != 3 || plot.Tail[2] != "Computing table 3\n" {
+		t.Errorf("unexpected tail: %q", plot.Tail)
+	}
+}
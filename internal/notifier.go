@@ -0,0 +1,186 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	EventPlotFinished     = "plot_finished"
+	EventPlotErrored      = "plot_errored"
+	EventPlotStalled      = "plot_stalled"
+	EventDiskFull         = "disk_full"
+	EventDiskFullSoon     = "disk_full_soon"
+	EventSchedulerBlocked = "scheduler_blocked"
+)
+
+const (
+	defaultNotificationInterval = 60 // minutes
+	defaultNotificationsPerHour = 20
+)
+
+// WebhookConfig is a single notification target.
+type WebhookConfig struct {
+	URL    string
+	Format string   // "json" (default), "slack", "discord" or "telegram"
+	ChatId string   // telegram only
+	Events []string // events to send, all events if empty
+}
+
+// NotificationConfig configures the events POSTed to webhooks by the server.
+type NotificationConfig struct {
+	Webhooks       []WebhookConfig
+	BlockedMinutes int // report the scheduler as blocked after this many minutes, 0 disables
+	DiskFullHours  int // report destination directories forecast to be full within this many hours, 0 disables
+	MinInterval    int // minutes before the same event is repeated for the same subject, default 60
+	MaxPerHour     int // maximum notifications sent per hour, default 20
+}
+
+func (nc *NotificationConfig) minInterval() time.Duration {
+	if nc.MinInterval > 0 {
+		return time.Duration(nc.MinInterval) * time.Minute
+	}
+	return defaultNotificationInterval * time.Minute
+}
+
+func (nc *NotificationConfig) maxPerHour() int {
+	if nc.MaxPerHour > 0 {
+		return nc.MaxPerHour
+	}
+	return defaultNotificationsPerHour
+}
+
+// Event is a notification sent to the webhooks, and is the payload of the "json" format.
+type Event struct {
+	Type    string
+	Host    string
+	Subject string // plot id or directory the event is about
+	Message string
+	Time    time.Time
+}
+
+func (ev *Event) key() string {
+	return ev.Type + "||" + ev.Subject
+}
+
+// Notifier sends events to webhooks, dropping repeats and anything over the hourly limit but the outcome
+// of plots.
+type Notifier struct {
+	Host     string
+	Disabled bool // simulations do not notify
+
+	lock        sync.Mutex
+	lastSent    map[string]time.Time
+	lastDropped map[string]time.Time // last event logged as dropped by the hourly limit
+	sent        []time.Time
+}
+
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// Notify sends the event to every matching webhook in the background.
+func (n *Notifier) Notify(config *NotificationConfig, ev Event) {
+	if config == nil || len(config.Webhooks) == 0 || n.Disabled {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	ev.Host = n.Host
+	if !n.allow(config, ev) {
+		return
+	}
+	for _, webhook := range config.Webhooks {
+		if webhook.wants(ev.Type) {
+			go func(webhook WebhookConfig) {
+				if err := webhook.send(ev); err != nil {
+					log.Printf("Failed to send notification to [%s]: %s", webhook.URL, err)
+				}
+			}(webhook)
+		}
+	}
+}
+
+// allow reports whether the event can be sent.  Repeats are dropped silently, as conditions such as a
+// full disk are checked every minute, and events dropped by the hourly limit are logged once per
+// MinInterval.  The outcome of plots is not limited, every plot finishing or failing once, even plots
+// failing before the plotter logged their id.
+func (n *Notifier) allow(config *NotificationConfig, ev Event) bool {
+	if ev.Type == EventPlotFinished || ev.Type == EventPlotErrored {
+		return true
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.lastSent == nil {
+		n.lastSent = map[string]time.Time{}
+		n.lastDropped = map[string]time.Time{}
+	}
+	if last, ok := n.lastSent[ev.key()]; ok && ev.Time.Sub(last) < config.minInterval() {
+		return false
+	}
+	sent := n.sent[:0]
+	for _, t := range n.sent {
+		if ev.Time.Sub(t) < time.Hour {
+			sent = append(sent, t)
+		}
+	}
+	n.sent = sent
+	if len(n.sent) >= config.maxPerHour() {
+		if last, ok := n.lastDropped[ev.key()]; !ok || ev.Time.Sub(last) >= config.minInterval() {
+			n.lastDropped[ev.key()] = ev.Time
+			log.Printf("Notification dropped by rate limit: %s %s", ev.Type, ev.Subject)
+		}
+		return false
+	}
+	n.sent = append(n.sent, ev.Time)
+	n.lastSent[ev.key()] = ev.Time
+	return true
+}
+
+func (wc *WebhookConfig) wants(eventType string) bool {
+	if len(wc.Events) == 0 {
+		return true
+	}
+	for _, e := range wc.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (wc *WebhookConfig) payload(ev Event) interface{} {
+	text := fmt.Sprintf("[%s] %s: %s", ev.Host, ev.Type, ev.Message)
+	switch wc.Format {
+	case "slack":
+		return map[string]string{"text": text}
+	case "discord":
+		return map[string]string{"content": text}
+	case "telegram":
+		return map[string]string{"chat_id": wc.ChatId, "text": text}
+	default:
+		return ev
+	}
+}
+
+func (wc *WebhookConfig) send(ev Event) error {
+	body, err := json.Marshal(wc.payload(ev))
+	if err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(wc.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
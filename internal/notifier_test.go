@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newWebhookStub(t *testing.T) (*httptest.Server, chan map[string]interface{}) {
+	t.Helper()
+	received := make(chan map[string]interface{}, 10)
+	stub := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		var payload map[string]interface{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("invalid payload: %s", body)
+		}
+		received <- payload
+	}))
+	t.Cleanup(stub.Close)
+	return stub, received
+}
+
+func waitForPayload(t *testing.T, received chan map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	select {
+	case payload := <-received:
+		return payload
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification received")
+	}
+	return nil
+}
+
+func TestNotifierFormats(t *testing.T) {
+	stub, received := newWebhookStub(t)
+	notifier := &Notifier{Host: "plotter1"}
+	config := &NotificationConfig{
+		Webhooks: []WebhookConfig{
+			{URL: stub.URL},
+			{URL: stub.URL, Format: "slack"},
+			{URL: stub.URL, Format: "telegram", ChatId: "42"},
+			{URL: stub.URL, Format: "discord", Events: []string{EventDiskFull}},
+		},
+	}
+	notifier.Notify(config, Event{Type: EventPlotFinished, Subject: "abc", Message: "done"})
+
+	payloads := map[string]map[string]interface{}{}
+	for i := 0; i < 3; i++ {
+		payload := waitForPayload(t, received)
+		switch {
+		case payload["Type"] != nil:
+			payloads["json"] = payload
+		case payload["chat_id"] != nil:
+			payloads["telegram"] = payload
+		default:
+			payloads["slack"] = payload
+		}
+	}
+	if payloads["json"]["Type"] != EventPlotFinished || payloads["json"]["Host"] != "plotter1" || payloads["json"]["Subject"] != "abc" {
+		t.Errorf("unexpected json payload: %v", payloads["json"])
+	}
+	if payloads["slack"]["text"] != "[plotter1] plot_finished: done" {
+		t.Errorf("unexpected slack payload: %v", payloads["slack"])
+	}
+	if payloads["telegram"]["chat_id"] != "42" || payloads["telegram"]["text"] != "[plotter1] plot_finished: done" {
+		t.Errorf("unexpected telegram payload: %v", payloads["telegram"])
+	}
+	select {
+	case payload := <-received:
+		t.Errorf("discord webhook should have filtered the event: %v", payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNotifierRateLimit(t *testing.T) {
+	notifier := &Notifier{}
+	config := &NotificationConfig{MinInterval: 10, MaxPerHour: 3}
+
+	now := initialTime
+	diskFull := Event{Type: EventDiskFull, Subject: "/mnt/t1", Time: now}
+	if !notifier.allow(config, diskFull) {
+		t.Error("first event should be sent")
+	}
+	diskFull.Time = now.Add(5 * time.Minute)
+	if notifier.allow(config, diskFull) {
+		t.Error("repeated event should be dropped")
+	}
+	diskFull.Time = now.Add(11 * time.Minute)
+	if !notifier.allow(config, diskFull) {
+		t.Error("event should be repeated after MinInterval")
+	}
+	if !notifier.allow(config, Event{Type: EventPlotStalled, Subject: "a", Time: now.Add(12 * time.Minute)}) {
+		t.Error("different subject should be sent")
+	}
+	if notifier.allow(config, Event{Type: EventPlotStalled, Subject: "b", Time: now.Add(13 * time.Minute)}) {
+		t.Error("event over MaxPerHour should be dropped")
+	}
+	for _, ev := range []Event{{Type: EventPlotFinished, Subject: "d"}, {Type: EventPlotErrored, Subject: "e"}} {
+		if ev.Time = now.Add(14 * time.Minute); !notifier.allow(config, ev) {
+			t.Errorf("%s over MaxPerHour should be sent", ev.Type)
+		}
+	}
+	// plots failing before the plotter logged their id are all notified
+	for i := 0; i < 2; i++ {
+		if !notifier.allow(config, Event{Type: EventPlotErrored, Time: now.Add(15 * time.Minute)}) {
+			t.Errorf("errored plot %d without an id should be sent", i)
+		}
+	}
+	if !notifier.allow(config, Event{Type: EventPlotStalled, Subject: "c", Time: now.Add(61 * time.Minute)}) {
+		t.Error("hourly limit should expire")
+	}
+
+	// an event dropped by the hourly limit does not hold back the next one for MinInterval
+	notifier = &Notifier{}
+	config.MaxPerHour = 1
+	notifier.allow(config, Event{Type: EventPlotStalled, Subject: "a", Time: now})
+	if notifier.allow(config, Event{Type: EventPlotStalled, Subject: "b", Time: now.Add(55 * time.Minute)}) {
+		t.Error("event over MaxPerHour should be dropped")
+	}
+	if !notifier.allow(config, Event{Type: EventPlotStalled, Subject: "b", Time: now.Add(61 * time.Minute)}) {
+		t.Error("event dropped by the hourly limit should be sent once the limit allows it")
+	}
+}
@@ -0,0 +1,78 @@
+package internal
+
+// NumaNode is a NUMA node of the plotter: its cores and the temp directories on the drives attached to
+// it.  Plots using these temp directories are pinned to the node.
+type NumaNode struct {
+	Node          int      // node number, as in /sys/devices/system/node/node<Node>
+	CPUs          string   // cores of the node, eg. "0-15,32-47"
+	TempDirectory []string // temp directories local to the node
+}
+
+func (node *NumaNode) isLocal(plotDir string) bool {
+	for _, dir := range node.TempDirectory {
+		if dir == plotDir {
+			return true
+		}
+	}
+	return false
+}
+
+// numaNode returns the node a temp directory is local to, or nil.
+func (config *Config) numaNode(plotDir string) *NumaNode {
+	for i := range config.NumaNodes {
+		if config.NumaNodes[i].isLocal(plotDir) {
+			return &config.NumaNodes[i]
+		}
+	}
+	return nil
+}
+
+// countActiveNode counts the plots computing on a node, those copying their final file being left out.
+func (server *Server) countActiveNode(node *NumaNode) int {
+	return server.countComputing(node.isLocal)
+}
+
+// countComputing counts the plots computing in the temp directories matching inBucket.
+func (server *Server) countComputing(inBucket func(dir string) bool) (count int) {
+	for _, plot := range server.snapshots() {
+		if inBucket(plot.PlotDir) && plot.getCurrentPhase() < 5 {
+			count++
+		}
+	}
+	return
+}
+
+// pickTemp returns the index of the temp directory of the next plot: the next one in turn, or with
+// NumaNodes the next one of the node computing the fewest plots, among those below MaxActivePlotPerTemp.
+// The temp directories of no node are balanced with the nodes as if they were one more node.
+func (server *Server) pickTemp(config *Config) int {
+	current := server.currentTemp % len(config.TempDirectory)
+	if len(config.NumaNodes) == 0 {
+		return current
+	}
+	best, bestCount := -1, 0
+	pick := func(inBucket func(dir string) bool) {
+		index := -1
+		for j := 0; j < len(config.TempDirectory) && index < 0; j++ {
+			k := (current + j) % len(config.TempDirectory)
+			dir := config.TempDirectory[k]
+			if inBucket(dir) && (config.MaxActivePlotPerTemp == 0 || server.countActiveTemp(dir) < config.MaxActivePlotPerTemp) {
+				index = k
+			}
+		}
+		if index < 0 {
+			return
+		}
+		if count := server.countComputing(inBucket); best < 0 || count < bestCount {
+			best, bestCount = index, count
+		}
+	}
+	for i := range config.NumaNodes {
+		pick(config.NumaNodes[i].isLocal)
+	}
+	pick(func(dir string) bool { return config.numaNode(dir) == nil })
+	if best < 0 {
+		return current
+	}
+	return best
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCanCreateNewPlotBalancesNumaNodes(t *testing.T) {
+	svr := &Server{
+		config: &PlotConfig{
+			CurrentConfig: &Config{
+				TargetDirectory:       []string{"target1", "target2", "target3", "target4"},
+				TempDirectory:         []string{"nvme0a", "nvme0b", "nvme1a", "sata"},
+				NumberOfParallelPlots: 10,
+				MaxActivePlotPerTemp:  2,
+				NumaNodes: []NumaNode{
+					{Node: 0, CPUs: "0-7", TempDirectory: []string{"nvme0a", "nvme0b"}},
+					{Node: 1, CPUs: "8-15", TempDirectory: []string{"nvme1a"}},
+				},
+			},
+		},
+		active: make(map[int64]*ActivePlot),
+	}
+	now := initialTime
+
+	// node 0 is computing two plots, node 1 and the temp directories of no node one of them
+	svr.active[1] = &ActivePlot{PlotDir: "nvme0a", State: PlotRunning, Phase: "1/4"}
+	svr.active[2] = &ActivePlot{PlotDir: "nvme0b", State: PlotRunning, Phase: "3/4"}
+	svr.active[3] = &ActivePlot{PlotDir: "nvme1a", State: PlotRunning, Phase: "2/4"}
+	svr.active[8] = &ActivePlot{PlotDir: "sata", State: PlotRunning, Phase: "1/4"}
+	checkSuccess(t, svr, now, "target1", "nvme1a")
+
+	svr.active[4] = &ActivePlot{PlotDir: "nvme1a", State: PlotRunning, Phase: "1/4"}
+	// node 1 is busier, and its only temp directory is at MaxActivePlotPerTemp anyway, while the temp
+	// directories of no node are balanced as one more node
+	checkSuccess(t, svr, now, "target2", "sata")
+	svr.active[9] = &ActivePlot{PlotDir: "sata", State: PlotRunning, Phase: "1/4"}
+	checkSuccess(t, svr, now, "target3", "nvme0a")
+	svr.active[5] = &ActivePlot{PlotDir: "nvme0a", State: PlotRunning, Phase: "1/4"}
+	checkSuccess(t, svr, now, "target4", "nvme0b")
+
+	// plots copying their final file do not count
+	svr.active[1].Phase = "cp"
+	svr.active[2].Phase = "cp"
+	svr.active[6] = &ActivePlot{PlotDir: "nvme0b", State: PlotRunning, Phase: "1/4"}
+	if node := svr.config.CurrentConfig.numaNode("nvme0b"); node == nil || svr.countActiveNode(node) != 2 {
+		t.Errorf("expected 2 plots computing on node 0")
+	}
+	// every temp directory is full, the round robin goes on as without nodes
+	svr.active[7] = &ActivePlot{PlotDir: "nvme0a", State: PlotRunning, Phase: "1/4"}
+	checkFailure(t, svr, now, "staggering start")
+	checkFailure(t, svr, now, "skipping [nvme1a], too many temp plots")
+	delete(svr.active, 9)
+	checkSuccess(t, svr, now, "target1", "sata")
+	if node := svr.config.CurrentConfig.numaNode("sata"); node != nil {
+		t.Errorf("unexpected node %d for a temp directory of no node", node.Node)
+	}
+}
+
+func TestCreateCgroupNumaNode(t *testing.T) {
+	parent := t.TempDir()
+	plot := &ActivePlot{PlotId: 7, CPUSet: "8-15", NumaNode: "1"}
+	if err := plot.createCgroup(&ResourceConfig{CgroupParent: parent}); err != nil {
+		t.Fatal(err)
+	}
+	for file, expected := range map[string]string{
+		"cgroup.subtree_control": "+cpuset",
+		"plot-7/cpuset.cpus":     "8-15",
+		"plot-7/cpuset.mems":     "1",
+	} {
+		if data, err := ioutil.ReadFile(filepath.Join(parent, file)); err != nil || string(data) != expected {
+			t.Errorf("expected %s in %s, got %q: %v", expected, file, data, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package internal
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"log"
 	"os"
 	"sync"
@@ -31,9 +32,23 @@ type Config struct {
 	AsyncCopying           bool
 	BucketSize             int
 	SavePlotLogDir         string
+	PlotLogDir             string
+	PlotLogMaxCount        int
+	PlotLogMaxDays         int
+	PlotLogMaxMB           int
+	ArchiveMaxCount        int
+	ArchiveMaxDays         int
 	ChiaRoot               string
 	MadMaxPlotter          string
 	Tmp2                   string
+	StallMinutes           int
+	StallPhaseFactor       float64
+	KillStalledPlots       bool
+	AuthToken              string
+	Hooks                  HookConfig
+	Resources              ResourceConfig
+	NumaNodes              []NumaNode
+	Notifications          NotificationConfig
 }
 
 type PlotConfig struct {
@@ -69,3 +84,20 @@ func (pc *PlotConfig) ProcessConfig() (newConfigLoaded bool) {
 	}
 	return
 }
+
+// SaveConfig replaces the configuration file, the new configuration is loaded by the next ProcessConfig.
+func (pc *PlotConfig) SaveConfig(config *Config) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0600)
+	if fs, err := os.Stat(pc.ConfigPath); err == nil {
+		mode = fs.Mode().Perm()
+	}
+	tmp := pc.ConfigPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, pc.ConfigPath)
+}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	pollInterval   = 30 * time.Second
+	longPollWait   = 30 * time.Second
+	requestTimeout = 10 * time.Second
+	minRetryDelay  = 10 * time.Second
+	maxRetryDelay  = 10 * time.Minute
+)
+
+// waitHeader is set by the server to the milliseconds a long poll waited for a change, so clients
+// can tell the network latency apart from the wait.
+const waitHeader = "X-Plotng-Wait"
+
+// killedHeader is set by the server to the PlotId of the plot killed by DELETE /plots/{id}.  Older
+// servers answer any DELETE without killing anything, so clients only trust an answer carrying it.
+const killedHeader = "X-Plotng-Killed"
+
+// hostStatus describes the connection to a host.
+type hostStatus struct {
+	LastSeen  time.Time // last successful request
+	Latency   time.Duration
+	Failures  int // consecutive failed requests
+	NextRetry time.Time
+	Err       error
+}
+
+// hostWatcher keeps the Msg of a plotng-server up to date.  Servers which support deltas are long
+// polled so changes arrive as they happen, older servers are polled every 30 seconds.  Unreachable
+// servers are retried with an exponential backoff.
+type hostWatcher struct {
+	host   string
+	token  string
+	update func(msg *Msg, status hostStatus) // called on the watcher goroutine, msg is nil on errors
+}
+
+func (hw *hostWatcher) run(ctx context.Context) {
+	var msg *Msg
+	var status hostStatus
+	for ctx.Err() == nil {
+		query := url.Values{}
+		if msg != nil && msg.Version > 0 {
+			query.Set("since", strconv.FormatUint(msg.Version, 10))
+			query.Set("epoch", strconv.FormatInt(msg.Epoch, 10))
+			query.Set("wait", strconv.Itoa(int(longPollWait/time.Second)))
+		}
+		delta, latency, err := fetchMsg(ctx, hw.host, hw.token, query)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			msg = nil
+			status.Err = err
+			status.Failures++
+			delay := retryDelay(status.Failures)
+			status.NextRetry = time.Now().Add(delay)
+			hw.update(nil, status)
+			sleepContext(ctx, delay)
+			continue
+		}
+		msg = msg.apply(delta)
+		status = hostStatus{LastSeen: time.Now(), Latency: latency}
+		hw.update(msg, status)
+		if msg.Version == 0 {
+			sleepContext(ctx, pollInterval)
+		}
+	}
+}
+
+// retryDelay returns the delay before retrying a host after consecutive failures.
+func retryDelay(failures int) time.Duration {
+	delay := minRetryDelay
+	for i := 1; i < failures && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// fetchMsg gets the Msg of a server and the latency of the request, the query parameters are
+// described in Server.serveState.
+func fetchMsg(ctx context.Context, host string, token string, query url.Values) (*Msg, time.Duration, error) {
+	timeout := requestTimeout
+	if wait, err := strconv.Atoi(query.Get("wait")); err == nil {
+		timeout += time.Duration(wait) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := newHostRequest(ctx, "GET", host, token, "/?"+query.Encode())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start := time.Now()
+	if resp, err := streamClient.Do(req); err == nil {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, 0, fmt.Errorf("unauthorized, check the token of the host")
+		}
+		latency := time.Since(start)
+		if waited, err := strconv.ParseInt(resp.Header.Get(waitHeader), 10, 64); err == nil {
+			latency -= time.Duration(waited) * time.Millisecond
+		}
+		var msg Msg
+		decoder := gob.NewDecoder(resp.Body)
+		if err := decoder.Decode(&msg); err == nil {
+			return &msg, latency, nil
+		} else {
+			return nil, 0, fmt.Errorf("Failed to decode message: %w", err)
+		}
+	} else {
+		return nil, 0, err
+	}
+}
+
+// newHostRequest creates a request to a plotng-server, authenticated with the token of the host if it has one.
+func newHostRequest(ctx context.Context, method string, host string, token string, path string) (*http.Request, error) {
+	req, err := http.NewRequest(method, fmt.Sprintf("http://%s%s", host, path), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req.WithContext(ctx), nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
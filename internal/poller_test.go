@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	for failures, expected := range map[int]time.Duration{
+		1:  10 * time.Second,
+		2:  20 * time.Second,
+		3:  40 * time.Second,
+		7:  maxRetryDelay,
+		50: maxRetryDelay,
+	} {
+		if actual := retryDelay(failures); actual != expected {
+			t.Errorf("failures=%d: expected %s, got %s", failures, expected, actual)
+		}
+	}
+}
+
+func TestHostWatcher(t *testing.T) {
+	svr := &Server{
+		config: &PlotConfig{},
+		active: make(map[int64]*ActivePlot),
+		events: newEventHub(),
+		epoch:  1,
+	}
+	plot := &ActivePlot{PlotId: 1, State: PlotRunning, Phase: "1/4", events: svr.events}
+	plot.publishState()
+	svr.active[1] = plot
+	stub := httptest.NewServer(svr)
+	defer stub.Close()
+
+	updates := make(chan *Msg, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := &hostWatcher{
+		host: strings.TrimPrefix(stub.URL, "http://"),
+		update: func(msg *Msg, status hostStatus) {
+			if status.Err != nil || status.LastSeen.IsZero() {
+				t.Errorf("unexpected status: %+v", status)
+			}
+			updates <- msg
+		},
+	}
+	go watcher.run(ctx)
+	next := func() *Msg {
+		t.Helper()
+		select {
+		case msg := <-updates:
+			return msg
+		case <-time.After(5 * time.Second):
+			t.Fatal("no update received")
+		}
+		return nil
+	}
+
+	if msg := next(); len(msg.Actives) != 1 || msg.Delta {
+		t.Fatalf("unexpected first update: %+v", msg)
+	}
+
+	svr.lock.Lock()
+	svr.active[2] = &ActivePlot{PlotId: 2, State: PlotRunning, Phase: "1/4", events: svr.events}
+	svr.lock.Unlock()
+	svr.active[2].publishState()
+	if msg := next(); len(msg.Actives) != 2 {
+		t.Fatalf("change not pushed: %+v", msg)
+	}
+}
+
+func TestFetchMsgToken(t *testing.T) {
+	svr := &Server{
+		config: &PlotConfig{CurrentConfig: &Config{AuthToken: "secret"}},
+		active: make(map[int64]*ActivePlot),
+	}
+	stub := httptest.NewServer(svr)
+	defer stub.Close()
+	host := strings.TrimPrefix(stub.URL, "http://")
+
+	if _, _, err := fetchMsg(context.Background(), host, "", url.Values{}); err == nil {
+		t.Error("request without token accepted")
+	}
+	if _, _, err := fetchMsg(context.Background(), host, "wrong", url.Values{}); err == nil {
+		t.Error("request with wrong token accepted")
+	}
+	if _, _, err := fetchMsg(context.Background(), host, "secret", url.Values{}); err != nil {
+		t.Errorf("request with token failed: %s", err)
+	}
+}
@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ResourceConfig limits the resources used by each plotter process, so that parallel plots and the
+// farmer on the same machine do not starve each other.  Only supported on Linux.
+type ResourceConfig struct {
+	Nice         int               // niceness of the plotters, from -20 to 19, 0 to leave it unchanged
+	IONiceClass  string            // I/O scheduling class: "realtime", "best-effort" or "idle", empty to leave it unchanged
+	IONiceLevel  int               // priority within the realtime and best-effort classes, from 0 (highest) to 7
+	CPUSets      []string          // sets of cores the plots are pinned to in turn, eg. ["0-3", "4-7"]
+	TempCPUSets  map[string]string // set of cores by temp directory, used instead of CPUSets
+	CgroupParent string            // cgroup v2 directory in which a cgroup is created for each plot, eg. /sys/fs/cgroup/plotng
+	MemoryMax    string            // memory.max of the cgroup of each plot, eg. "8G"
+	CPUMax       float64           // number of CPUs the cgroup of each plot may use, eg. 2.5, 0 for no limit
+}
+
+// ioniceClasses are the I/O scheduling classes of ioprio_set.
+var ioniceClasses = map[string]int{
+	"realtime":    1,
+	"best-effort": 2,
+	"idle":        3,
+}
+
+// cpuSet returns the cores a plot using plotDir is pinned to: its TempCPUSets entry, or the next of
+// CPUSets.
+func (rc *ResourceConfig) cpuSet(plotDir string, next *int) string {
+	if set, ok := rc.TempCPUSets[plotDir]; ok {
+		return set
+	}
+	if len(rc.CPUSets) == 0 {
+		return ""
+	}
+	set := rc.CPUSets[*next%len(rc.CPUSets)]
+	*next = (*next + 1) % len(rc.CPUSets)
+	return set
+}
+
+// parseCPUSet parses a list of cores in the cpuset format, eg. "0-3,8,10-11".
+func parseCPUSet(s string) ([]int, error) {
+	var cpus []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid cpu set [%s]", s)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil || last < first {
+				return nil, fmt.Errorf("invalid cpu set [%s]", s)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// cpuMax returns the cpu.max value limiting a cgroup to a number of CPUs.
+func cpuMax(cpus float64) string {
+	const period = 100000
+	return fmt.Sprintf("%d %d", int64(cpus*period), period)
+}
+
+// joinCgroupScript moves the shell into the cgroup.procs file given as $0, then replaces the shell by the
+// plotter, so the plotter runs in the cgroup from its first instruction.
+const joinCgroupScript = `echo $$ > "$0" && exec "$@"`
+
+// startPlotter starts the plotter with the resource controls applied before it runs: it joins the cgroup
+// of the plot through a shell, and inherits the niceness, I/O priority, CPU affinity and NUMA memory
+// policy of the thread starting it.  The plot fails if its cgroup cannot be created, rather than run without its limits,
+// while the other controls are only logged when they fail.
+func (ap *ActivePlot) startPlotter(cmd *exec.Cmd, rc *ResourceConfig) error {
+	if len(rc.CgroupParent) > 0 {
+		if err := ap.createCgroup(rc); err != nil {
+			return fmt.Errorf("failed to create the cgroup of plot [%d]: %w", ap.PlotId, err)
+		}
+		cmd.Args = append([]string{"sh", "-c", joinCgroupScript, filepath.Join(ap.cgroup, "cgroup.procs"), cmd.Path}, cmd.Args[1:]...)
+		cmd.Path = "/bin/sh"
+	}
+	resourcesErr, err := startWithResources(cmd, rc, ap.CPUSet, ap.NumaNode)
+	if resourcesErr != nil {
+		log.Printf("Failed to apply the resource controls of plot [%d]: %s", ap.PlotId, resourcesErr)
+	}
+	return err
+}
+
+// createCgroup creates the cgroup of the plot with its limits.
+func (ap *ActivePlot) createCgroup(rc *ResourceConfig) error {
+	// the controllers must be enabled in the parent for its children to have limits
+	var controllers []string
+	if len(rc.MemoryMax) > 0 {
+		controllers = append(controllers, "+memory")
+	}
+	if rc.CPUMax > 0 {
+		controllers = append(controllers, "+cpu")
+	}
+	if len(ap.NumaNode) > 0 {
+		controllers = append(controllers, "+cpuset")
+	}
+	if len(controllers) > 0 {
+		if err := ioutil.WriteFile(filepath.Join(rc.CgroupParent, "cgroup.subtree_control"), []byte(strings.Join(controllers, " ")), 0644); err != nil {
+			return err
+		}
+	}
+	dir := filepath.Join(rc.CgroupParent, fmt.Sprintf("plot-%d", ap.PlotId))
+	if err := os.Mkdir(dir, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	ap.cgroup = dir
+	if len(rc.MemoryMax) > 0 {
+		if err := ioutil.WriteFile(filepath.Join(dir, "memory.max"), []byte(rc.MemoryMax), 0644); err != nil {
+			return err
+		}
+	}
+	if rc.CPUMax > 0 {
+		if err := ioutil.WriteFile(filepath.Join(dir, "cpu.max"), []byte(cpuMax(rc.CPUMax)), 0644); err != nil {
+			return err
+		}
+	}
+	if len(ap.NumaNode) > 0 {
+		// also allocates the memory of the plotter on its node
+		if err := ioutil.WriteFile(filepath.Join(dir, "cpuset.cpus"), []byte(ap.CPUSet), 0644); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "cpuset.mems"), []byte(ap.NumaNode), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeCgroup removes the cgroup of the plot once its process exited.
+func (ap *ActivePlot) removeCgroup() {
+	if len(ap.cgroup) == 0 {
+		return
+	}
+	if err := os.Remove(ap.cgroup); err != nil {
+		log.Printf("Failed to remove cgroup [%s]: %s", ap.cgroup, err)
+	}
+	ap.cgroup = ""
+}
@@ -0,0 +1,105 @@
+//go:build linux
+// +build linux
+
+package internal
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	ioprioWhoProcess = 1
+	mpolBind         = 2
+)
+
+// startWithResources starts cmd from a thread given the niceness, I/O priority, CPU affinity and memory
+// policy of the plotter, which the process inherits.  The thread is never unlocked, so it exits with its
+// goroutine instead of running the server with the attributes of the plotter.
+func startWithResources(cmd *exec.Cmd, rc *ResourceConfig, cpuSet string, numaNode string) (resourcesErr error, err error) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runtime.LockOSThread()
+		resourcesErr = setThreadResources(syscall.Gettid(), rc, cpuSet)
+		if len(numaNode) > 0 && resourcesErr == nil {
+			resourcesErr = bindMemory(numaNode)
+		}
+		err = cmd.Start()
+	}()
+	<-done
+	return resourcesErr, err
+}
+
+// bindMemory restricts the memory allocations of the calling thread, and of the processes it starts, to
+// the NUMA nodes, like numactl --membind.
+func bindMemory(nodes string) error {
+	mask, err := bitMask(nodes)
+	if err != nil {
+		return err
+	}
+	// the kernel reads maxnode - 1 bits of the mask
+	if _, _, errno := syscall.Syscall(syscall.SYS_SET_MEMPOLICY, mpolBind, uintptr(unsafe.Pointer(&mask[0])), uintptr(len(mask)*64+1)); errno != 0 {
+		return fmt.Errorf("set_mempolicy: %w", errno)
+	}
+	return nil
+}
+
+// bitMask returns the mask of a list in the cpuset format, as the unsigned longs of the system calls.
+func bitMask(list string) ([]uint64, error) {
+	bits, err := parseCPUSet(list)
+	if err != nil {
+		return nil, err
+	}
+	var mask []uint64
+	for _, bit := range bits {
+		for len(mask) <= bit/64 {
+			mask = append(mask, 0)
+		}
+		mask[bit/64] |= 1 << uint(bit%64)
+	}
+	return mask, nil
+}
+
+// setThreadResources sets the niceness, I/O priority and CPU affinity of a thread.  These are thread
+// attributes on Linux, inherited by the threads and processes it creates.
+func setThreadResources(tid int, rc *ResourceConfig, cpuSet string) error {
+	if rc.Nice == 0 && len(rc.IONiceClass) == 0 && len(cpuSet) == 0 {
+		return nil
+	}
+	var mask []uint64
+	if len(cpuSet) > 0 {
+		var err error
+		if mask, err = bitMask(cpuSet); err != nil {
+			return err
+		}
+	}
+	ioprio := 0
+	if len(rc.IONiceClass) > 0 {
+		class, ok := ioniceClasses[rc.IONiceClass]
+		if !ok {
+			return fmt.Errorf("invalid IONiceClass [%s]", rc.IONiceClass)
+		}
+		ioprio = class<<13 | rc.IONiceLevel&7
+	}
+
+	if rc.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, rc.Nice); err != nil {
+			return fmt.Errorf("setpriority: %w", err)
+		}
+	}
+	if ioprio != 0 {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(ioprio)); errno != 0 {
+			return fmt.Errorf("ioprio_set: %w", errno)
+		}
+	}
+	if len(mask) > 0 {
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, uintptr(tid), uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0]))); errno != 0 {
+			return fmt.Errorf("sched_setaffinity: %w", errno)
+		}
+	}
+	return nil
+}
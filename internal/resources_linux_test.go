@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestStartPlotter(t *testing.T) {
+	// a directory stands for the parent cgroup, the shell writing the pid of the plotter in cgroup.procs
+	parent := t.TempDir()
+	plot := &ActivePlot{PlotId: 42, CPUSet: "0", NumaNode: "0"}
+	cmd := exec.Command("sleep", "10")
+	if err := plot.startPlotter(cmd, &ResourceConfig{Nice: 5, IONiceClass: "idle", CgroupParent: parent}); err != nil {
+		t.Skip(err)
+	}
+	defer cmd.Process.Kill()
+	pid := cmd.Process.Pid
+	// the getpriority system call returns 20 - nice
+	if prio, err := syscall.Getpriority(syscall.PRIO_PROCESS, pid); err != nil || prio != 15 {
+		t.Errorf("expected nice 5, got %d: %v", 20-prio, err)
+	}
+	if prio, err := syscall.Getpriority(syscall.PRIO_PROCESS, 0); err != nil || prio == 15 {
+		t.Errorf("the niceness of the plotter should not leak into the server: %v", err)
+	}
+	status, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil || !strings.Contains(string(status), "Cpus_allowed_list:\t0\n") {
+		t.Errorf("expected the process pinned to cpu 0: %v", err)
+	}
+	// numa_maps only exists on kernels supporting NUMA
+	if maps, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/numa_maps", pid)); err == nil && !strings.Contains(string(maps), "bind:0") {
+		t.Errorf("expected the memory bound to node 0:\n%s", maps)
+	}
+	procs := filepath.Join(parent, "plot-42", "cgroup.procs")
+	for i := 0; i < 100; i++ {
+		if data, _ := ioutil.ReadFile(procs); strings.TrimSpace(string(data)) == strconv.Itoa(pid) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if data, err := ioutil.ReadFile(procs); err != nil || strings.TrimSpace(string(data)) != strconv.Itoa(pid) {
+		t.Errorf("expected %d in cgroup.procs, got %q: %v", pid, data, err)
+	}
+
+	if err := setThreadResources(pid, &ResourceConfig{IONiceClass: "fast"}, ""); err == nil {
+		t.Error("expected an invalid IONiceClass to fail")
+	}
+	if err := (&ActivePlot{PlotId: 43}).startPlotter(exec.Command("true"), &ResourceConfig{CgroupParent: filepath.Join(parent, "missing")}); err == nil {
+		t.Error("expected the plot to fail without its cgroup")
+	}
+}
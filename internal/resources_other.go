@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package internal
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// startWithResources starts cmd, the niceness, I/O priority, CPU affinity and memory policy are only
+// supported on Linux.
+func startWithResources(cmd *exec.Cmd, rc *ResourceConfig, cpuSet string, numaNode string) (resourcesErr error, err error) {
+	if rc.Nice != 0 || len(rc.IONiceClass) > 0 || len(cpuSet) > 0 || len(numaNode) > 0 {
+		resourcesErr = errors.New("Nice, IONiceClass, CPUSets and NumaNodes are only supported on Linux")
+	}
+	return resourcesErr, cmd.Start()
+}
@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCPUSets(t *testing.T) {
+	if cpus, err := parseCPUSet("0-3, 8,10-11"); err != nil || !reflect.DeepEqual(cpus, []int{0, 1, 2, 3, 8, 10, 11}) {
+		t.Errorf("unexpected cpus %v: %v", cpus, err)
+	}
+	for _, invalid := range []string{"", "a", "3-1", "-1"} {
+		if _, err := parseCPUSet(invalid); err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+
+	rc := &ResourceConfig{CPUSets: []string{"0-3", "4-7"}, TempCPUSets: map[string]string{"/nvme": "8-15"}}
+	next := 0
+	var sets []string
+	for _, plotDir := range []string{"/ssd1", "/nvme", "/ssd2", "/ssd1"} {
+		sets = append(sets, rc.cpuSet(plotDir, &next))
+	}
+	if !reflect.DeepEqual(sets, []string{"0-3", "8-15", "4-7", "0-3"}) {
+		t.Errorf("unexpected cpu sets %v", sets)
+	}
+	if set := (&ResourceConfig{}).cpuSet("/ssd1", &next); set != "" {
+		t.Errorf("expected no cpu set, got %s", set)
+	}
+	if max := cpuMax(2.5); max != "250000 100000" {
+		t.Errorf("unexpected cpu.max %s", max)
+	}
+}
+
+func TestCreateCgroup(t *testing.T) {
+	// a directory stands for the parent cgroup, the files written are checked instead of their effect
+	parent := t.TempDir()
+	plot := &ActivePlot{PlotId: 42}
+	if err := plot.createCgroup(&ResourceConfig{CgroupParent: parent, MemoryMax: "8G", CPUMax: 4}); err != nil {
+		t.Fatal(err)
+	}
+	for file, expected := range map[string]string{
+		"cgroup.subtree_control": "+memory +cpu",
+		"plot-42/memory.max":     "8G",
+		"plot-42/cpu.max":        "400000 100000",
+	} {
+		if data, err := ioutil.ReadFile(filepath.Join(parent, file)); err != nil || string(data) != expected {
+			t.Errorf("expected %s in %s, got %q: %v", expected, file, data, err)
+		}
+	}
+	if plot.cgroup != filepath.Join(parent, "plot-42") {
+		t.Errorf("unexpected cgroup %s", plot.cgroup)
+	}
+}
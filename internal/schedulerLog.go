@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"sync"
+	"time"
+)
+
+// schedulerLogSize is the number of scheduler decisions kept, a day of decisions taken every minute
+// when they all differ.
+const schedulerLogSize = 24 * 60
+
+// SchedulerEntry is a decision of the scheduler, repeated Count times from Time to LastTime.
+type SchedulerEntry struct {
+	Time     time.Time
+	LastTime time.Time
+	Count    int
+	Started  bool   // a plot was started
+	Status   string // the reason when no plot was started
+}
+
+// schedulerLog is a ring buffer of the latest scheduler decisions, where consecutive identical decisions
+// are merged.
+type schedulerLog struct {
+	lock    sync.Mutex
+	entries []SchedulerEntry
+	next    int // index of the oldest entry once the buffer is full
+}
+
+func (sl *schedulerLog) add(t time.Time, started bool, status string) {
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+	if len(sl.entries) > 0 {
+		last := &sl.entries[(sl.next+len(sl.entries)-1)%len(sl.entries)]
+		if !started && !last.Started && last.Status == status {
+			last.LastTime = t
+			last.Count++
+			return
+		}
+	}
+	entry := SchedulerEntry{Time: t, LastTime: t, Count: 1, Started: started, Status: status}
+	if len(sl.entries) < schedulerLogSize {
+		sl.entries = append(sl.entries, entry)
+		return
+	}
+	sl.entries[sl.next] = entry
+	sl.next = (sl.next + 1) % len(sl.entries)
+}
+
+// Entries returns the decisions kept, the oldest first.
+func (sl *schedulerLog) Entries() []SchedulerEntry {
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+	entries := make([]SchedulerEntry, 0, len(sl.entries))
+	entries = append(entries, sl.entries[sl.next:]...)
+	return append(entries, sl.entries[:sl.next]...)
+}
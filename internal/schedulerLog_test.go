@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchedulerLog(t *testing.T) {
+	var sl schedulerLog
+	at := initialTime
+	sl.add(at, false, "too many active plots")
+	sl.add(at.Add(time.Minute), false, "too many active plots")
+	sl.add(at.Add(2*time.Minute), true, "Creating plot")
+	sl.add(at.Add(3*time.Minute), true, "Creating plot")
+	entries := sl.Entries()
+	if len(entries) != 3 || entries[0].Count != 2 || !entries[0].LastTime.Equal(at.Add(time.Minute)) || !entries[1].Started || entries[2].Count != 1 {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+
+	// the oldest decisions are dropped once the buffer is full
+	for i := 0; i < schedulerLogSize+10; i++ {
+		sl.add(at.Add(time.Duration(i)*time.Minute), false, string(rune('a'+i%2)))
+	}
+	entries = sl.Entries()
+	if len(entries) != schedulerLogSize {
+		t.Fatalf("expected %d entries, got %d", schedulerLogSize, len(entries))
+	}
+	if !entries[0].Time.Equal(at.Add(10*time.Minute)) || !entries[len(entries)-1].Time.Equal(at.Add(time.Duration(schedulerLogSize+9)*time.Minute)) {
+		t.Errorf("unexpected oldest %s or latest %s", entries[0].Time, entries[len(entries)-1].Time)
+	}
+
+	svr := &Server{config: &PlotConfig{}}
+	svr.scheduler.add(at, false, "no temp dir with enough space")
+	resp := httptest.NewRecorder()
+	svr.ServeHTTP(resp, httptest.NewRequest("GET", "/api/scheduler", nil))
+	var served []SchedulerEntry
+	if err := json.NewDecoder(resp.Body).Decode(&served); err != nil || len(served) != 1 || served[0].Status != "no temp dir with enough space" {
+		t.Errorf("unexpected response %+v err=%v", served, err)
+	}
+	if text := schedulerLogText(served); !strings.Contains(text, "no temp dir with enough space") {
+		t.Errorf("unexpected text %q", text)
+	}
+}
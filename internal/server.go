@@ -1,12 +1,22 @@
 package internal
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/subtle"
 	"encoding/gob"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,13 +24,26 @@ import (
 )
 
 type Server struct {
+	Announce   bool        // announce the server on the LAN for plotng-client -discover
+	Simulation *Simulation // runs a fake plotter on an accelerated clock instead of the plotter
+
 	config               *PlotConfig
 	active               map[int64]*ActivePlot
 	archive              []*ActivePlot
+	archiveCutoff        time.Time // archived plots which ended before it were pruned
+	scheduler            schedulerLog
 	currentTemp          int
 	currentTarget        int
+	nextCPUSet           int
 	targetDelayStartTime time.Time
 	lastStatus           string
+	blockedSince         time.Time
+	clock                *clock
+	notifier             *Notifier
+	events               *eventHub
+	epoch                int64
+	diskSpace            map[string]diskSpaceEntry
+	diskSpaceLock        sync.Mutex
 	lock                 sync.RWMutex
 }
 
@@ -33,15 +56,39 @@ func (server *Server) ProcessLoop(configPath string, host string, port int) {
 		}
 	}()
 
+	hostname, _ := os.Hostname()
+	server.setup(configPath, hostname)
+	if server.Simulation != nil {
+		if err := server.Simulation.start(time.Now()); err != nil {
+			log.Fatalf("Failed to start the simulation: %s", err)
+		}
+		server.clock = server.Simulation.clock
+		server.notifier.Disabled = true
+		log.Printf("Simulating plots %.0f times faster than real time", server.Simulation.Speed)
+	}
+	if server.Announce {
+		go announce(context.Background(), hostname, port)
+	}
+	server.createPlot(server.clock.Now())
+	ticker := time.NewTicker(server.clock.Real(time.Minute))
+	for range ticker.C {
+		now := server.clock.Now()
+		server.createPlot(now)
+		if server.Simulation != nil && server.Simulation.report(now) {
+			return
+		}
+	}
+}
+
+// setup prepares the server to plot with the configuration file at configPath.
+func (server *Server) setup(configPath string, hostname string) {
 	server.config = &PlotConfig{
 		ConfigPath: configPath,
 	}
 	server.active = map[int64]*ActivePlot{}
-	server.createPlot(time.Now())
-	ticker := time.NewTicker(time.Minute)
-	for t := range ticker.C {
-		server.createPlot(t)
-	}
+	server.notifier = &Notifier{Host: hostname}
+	server.events = newEventHub()
+	server.epoch = time.Now().UnixNano()
 }
 
 func (server *Server) createPlot(t time.Time) {
@@ -51,28 +98,204 @@ func (server *Server) createPlot(t time.Time) {
 	if server.config.CurrentConfig != nil {
 		server.config.Lock.RLock()
 		server.lock.Lock()
-		if targetDir, plotDir, err := server.canCreateNewPlot(server.config.CurrentConfig, time.Now()); err == nil {
+		lastStatus := server.lastStatus
+		if targetDir, plotDir, err := server.canCreateNewPlot(server.config.CurrentConfig, t); err == nil {
 			server.createNewPlot(server.config.CurrentConfig, targetDir, plotDir)
 			server.lastStatus = "Creating plot"
+			server.blockedSince = time.Time{}
+			server.scheduler.add(t, true, fmt.Sprintf("Creating plot in [%s] for [%s]", plotDir, targetDir))
 		} else {
 			log.Printf("Skipping new plot: %v", err)
 			server.lastStatus = err.Error()
+			server.scheduler.add(t, false, server.lastStatus)
+			server.checkBlocked(server.config.CurrentConfig, t, err)
+		}
+		server.pruneArchive(server.config.CurrentConfig, t)
+		server.checkDiskFull(server.config.CurrentConfig, t)
+		server.checkStalled(server.config.CurrentConfig, t)
+		if server.lastStatus != lastStatus {
+			server.events.publish(PlotEvent{Kind: PlotEventServer})
 		}
 
 		server.lock.Unlock()
 		server.config.Lock.RUnlock()
 	}
+	server.config.Lock.RLock()
+	server.lock.Lock()
 	fmt.Printf("%s, %d Active Plots\n", t.Format("2006-01-02 15:04:05"), len(server.active))
 	for _, plot := range server.active {
-		fmt.Print(plot.String(server.config.CurrentConfig.ShowPlotLog))
-		if plot.State == PlotFinished || plot.State == PlotError {
+		snapshot := plot.snapshot()
+		fmt.Print(snapshot.String(server.config.CurrentConfig.ShowPlotLog))
+		if snapshot.State == PlotFinished || snapshot.State == PlotError || snapshot.State == PlotKilled {
+			// the plotter no longer changes the plot once it ended
+			server.notifyPlotDone(snapshot)
 			server.archive = append(server.archive, plot)
+			plot.publish(PlotEvent{PlotId: plot.PlotId, Id: snapshot.Id, Kind: PlotEventServer})
 			delete(server.active, plot.PlotId)
 		}
 	}
+	server.lock.Unlock()
+	server.config.Lock.RUnlock()
 	fmt.Println(" ")
 }
 
+// checkBlocked tracks how long the scheduler has been unable to start a plot while below the parallel
+// plot limit, and notifies once that exceeds Notifications.BlockedMinutes.
+func (server *Server) checkBlocked(config *Config, now time.Time, reason error) {
+	if server.countActivePlots() >= config.NumberOfParallelPlots {
+		server.blockedSince = time.Time{}
+		return
+	}
+	if server.blockedSince.IsZero() {
+		server.blockedSince = now
+		return
+	}
+	blockedFor := now.Sub(server.blockedSince)
+	if config.Notifications.BlockedMinutes > 0 && blockedFor >= time.Duration(config.Notifications.BlockedMinutes)*time.Minute {
+		server.notifier.Notify(&config.Notifications, Event{
+			Type:    EventSchedulerBlocked,
+			Subject: "scheduler",
+			Message: fmt.Sprintf("no plot started for %s: %s", DurationString(blockedFor), reason),
+		})
+	}
+}
+
+func (server *Server) checkDiskFull(config *Config, now time.Time) {
+	if len(config.Notifications.Webhooks) == 0 {
+		return
+	}
+	available := map[string]uint64{}
+	for _, dir := range config.TargetDirectory {
+		available[dir] = server.getDiskSpaceAvailable(dir)
+	}
+	actives := server.snapshots()
+	window := time.Duration(config.Notifications.DiskFullHours) * time.Hour
+	for _, ev := range diskFullEvents(available, actives, server.archive, now, window) {
+		server.notifier.Notify(&config.Notifications, ev)
+	}
+}
+
+// diskFullEvents returns a disk_full event for each destination directory with less space than a plot,
+// and a disk_full_soon event for each one, and for all of them together, forecast to be full within
+// window (0 disables the forecast).
+func diskFullEvents(available map[string]uint64, actives []*ActivePlot, archive []*ActivePlot, now time.Time, window time.Duration) []Event {
+	var dirs []string
+	for dir := range available {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	var events []Event
+	var forecasts []diskForecast
+	for _, dir := range dirs {
+		space := available[dir]
+		if space < PLOT_SIZE {
+			events = append(events, Event{
+				Type:    EventDiskFull,
+				Subject: dir,
+				Message: fmt.Sprintf("[%s] has only %s available", dir, SpaceString(space)),
+			})
+		}
+		if window <= 0 {
+			continue
+		}
+		forecast := forecastDiskFull(dir, space, actives, archive, now)
+		forecasts = append(forecasts, forecast)
+		if space >= PLOT_SIZE && !forecast.FullAt.IsZero() && forecast.FullAt.Sub(now) < window {
+			events = append(events, Event{
+				Type:    EventDiskFullSoon,
+				Subject: dir,
+				Message: fmt.Sprintf("[%s] is expected to be full in %s, at %s", dir, DurationString(forecast.FullAt.Sub(now)),
+					forecast.FullAt.Format("2006-01-02 15:04")),
+			})
+		}
+	}
+	if len(forecasts) > 1 {
+		if at := forecastFarmFull(forecasts, now); !at.IsZero() && at.Sub(now) < window {
+			events = append(events, Event{
+				Type:    EventDiskFullSoon,
+				Subject: "*",
+				Message: fmt.Sprintf("All destination directories are expected to be full in %s, at %s", DurationString(at.Sub(now)),
+					at.Format("2006-01-02 15:04")),
+			})
+		}
+	}
+	return events
+}
+
+// checkStalled flags running plots which have not logged for StallMinutes, or which have spent more than
+// StallPhaseFactor times the historical duration in their current phase, optionally killing them.
+func (server *Server) checkStalled(config *Config, now time.Time) {
+	for _, plot := range server.active {
+		snapshot := plot.snapshot()
+		if snapshot.State != PlotRunning && snapshot.State != PlotStalled {
+			continue
+		}
+		reason := server.stallReason(config, snapshot, now)
+		if len(reason) == 0 {
+			if plot.switchState(PlotStalled, PlotRunning) {
+				log.Printf("Plot [%s] resumed", snapshot.Id)
+			}
+			continue
+		}
+		if snapshot.State == PlotRunning && plot.switchState(PlotRunning, PlotStalled) {
+			log.Printf("Plot [%s] stalled: %s", snapshot.Id, reason)
+			plot.appendLog(fmt.Sprintf("plotng: plot stalled, %s\n", reason))
+			server.notifier.Notify(&config.Notifications, Event{
+				Type:    EventPlotStalled,
+				Subject: snapshot.Id,
+				Message: fmt.Sprintf("plot [%s] stalled, %s", snapshot.Id, reason),
+			})
+		}
+		if config.KillStalledPlots {
+			log.Printf("Killing stalled plot [%s]", snapshot.Id)
+			plot.appendLog("plotng: killing stalled plot\n")
+			plot.kill()
+		}
+	}
+}
+
+// stallReason returns why the plot, a snapshot, is considered stalled, or an empty string if it is not.
+func (server *Server) stallReason(config *Config, plot *ActivePlot, now time.Time) string {
+	if config.StallMinutes > 0 {
+		lastLog := plot.LastLogTime
+		if lastLog.IsZero() {
+			lastLog = plot.StartTime
+		}
+		if idle := now.Sub(lastLog); !lastLog.IsZero() && idle >= time.Duration(config.StallMinutes)*time.Minute {
+			return fmt.Sprintf("no output for %s", DurationString(idle))
+		}
+	}
+	phase := plot.getCurrentPhase()
+	if config.StallPhaseFactor > 0 && phase >= 1 && phase <= phaseCount {
+		expected, count := expectedPhaseDurations(server.archive, plot)
+		phaseStart := plot.getPhaseTime(phase - 1)
+		if count > 0 && expected[phase-1] > 0 && !phaseStart.IsZero() {
+			elapsed := now.Sub(phaseStart)
+			if float64(elapsed) > config.StallPhaseFactor*float64(expected[phase-1]) {
+				return fmt.Sprintf("phase %s running for %s, expected %s", plot.Phase, DurationString(elapsed), DurationString(expected[phase-1]))
+			}
+		}
+	}
+	return ""
+}
+
+// notifyPlotDone notifies the end of a plot.  Must be called with the configuration lock held.
+func (server *Server) notifyPlotDone(plot *ActivePlot) {
+	if server.config.CurrentConfig == nil || plot.State == PlotKilled {
+		return
+	}
+	ev := Event{
+		Type:    EventPlotFinished,
+		Subject: plot.Id,
+		Message: fmt.Sprintf("plot [%s] finished in %s, %s -> %s", plot.Id, DurationString(plot.EndTime.Sub(plot.StartTime)), plot.PlotDir, plot.TargetDir),
+	}
+	if plot.State == PlotError {
+		ev.Type = EventPlotErrored
+		ev.Message = fmt.Sprintf("plot [%s] failed in phase %s, %s -> %s", plot.Id, plot.Phase, plot.PlotDir, plot.TargetDir)
+	}
+	server.notifier.Notify(&server.config.CurrentConfig.Notifications, ev)
+}
+
 func (server *Server) canCreateNewPlot(config *Config, now time.Time) (string, string, error) {
 	if len(config.TempDirectory) == 0 || len(config.TargetDirectory) == 0 {
 		return "", "", errors.New("configuration lacks TempDirectory or TargetDirectory")
@@ -94,8 +317,8 @@ func (server *Server) canCreateNewPlot(config *Config, now time.Time) (string, s
 	}
 	if config.MaxActivePlotPerPhase1 > 0 {
 		var sum int
-		for _, plot := range server.active {
-			if plot.State == PlotRunning && plot.getCurrentPhase() <= 1 {
+		for _, plot := range server.snapshots() {
+			if (plot.State == PlotRunning || plot.State == PlotStalled) && plot.getCurrentPhase() <= 1 {
 				sum++
 			}
 		}
@@ -104,8 +327,9 @@ func (server *Server) canCreateNewPlot(config *Config, now time.Time) (string, s
 			return "", "", fmt.Errorf("too many active plots in phase 1: %d", sum)
 		}
 	}
-	plotDir := config.TempDirectory[server.currentTemp]
-	server.currentTemp++
+	index := server.pickTemp(config)
+	plotDir := config.TempDirectory[index]
+	server.currentTemp = index + 1
 	if server.currentTemp >= len(config.TempDirectory) {
 		server.currentTemp = 0
 	}
@@ -131,7 +355,7 @@ func (server *Server) canCreateNewPlot(config *Config, now time.Time) (string, s
 }
 
 func (server *Server) createNewPlot(config *Config, targetDir string, plotDir string) {
-	t := time.Now()
+	t := server.clock.Now()
 	plot := &ActivePlot{
 		PlotId:           t.Unix(),
 		TargetDir:        targetDir,
@@ -151,9 +375,31 @@ func (server *Server) createNewPlot(config *Config, targetDir string, plotDir st
 		Phase:            "NA",
 		Tail:             nil,
 		State:            PlotRunning,
+		logStore:         newLogStore(config, server.config.ConfigPath),
+		events:           server.events,
+	}
+	if server.Simulation != nil {
+		plot.clock = server.clock
+		plot.logStore = nil
+		plot.SavePlotLogDir = ""
+		plot.simulated = &simulatedPlot{
+			durations: server.Simulation.durations(plotDir, server.countActiveTemp(plotDir)),
+			stop:      make(chan struct{}),
+		}
+	}
+	if node := config.numaNode(plotDir); node != nil {
+		plot.CPUSet = node.CPUs
+		plot.NumaNode = strconv.Itoa(node.Node)
+	} else {
+		plot.CPUSet = config.Resources.cpuSet(plotDir, &server.nextCPUSet)
 	}
 	server.active[plot.PlotId] = plot
-	go plot.RunPlot(config)
+	plot.publish(PlotEvent{PlotId: plot.PlotId, Kind: PlotEventServer})
+	if plot.simulated != nil {
+		go plot.runSimulated(server.Simulation, server.getRealDiskSpaceAvailable)
+	} else {
+		go plot.RunPlot(config)
+	}
 }
 
 func (server *Server) countActiveTarget(path string) (count int) {
@@ -165,9 +411,18 @@ func (server *Server) countActiveTarget(path string) (count int) {
 	return
 }
 
+// snapshots returns a snapshot of every active plot.  Must be called with the server lock held.
+func (server *Server) snapshots() []*ActivePlot {
+	var plots []*ActivePlot
+	for _, plot := range server.active {
+		plots = append(plots, plot.snapshot())
+	}
+	return plots
+}
+
 func (server *Server) countActivePlots() (count int) {
 	if server.config.CurrentConfig.AsyncCopying {
-		for _, plot := range server.active {
+		for _, plot := range server.snapshots() {
 			if plot.getCurrentPhase() < 4 {
 				count++
 			}
@@ -189,58 +444,519 @@ func (server *Server) countActiveTemp(path string) (count int) {
 }
 
 func (server *Server) getDiskSpaceAvailable(path string) uint64 {
+	if server.Simulation != nil {
+		return server.Simulation.available(path, server.getRealDiskSpaceAvailable)
+	}
+	return server.getRealDiskSpaceAvailable(path)
+}
+
+func (server *Server) getRealDiskSpaceAvailable(path string) uint64 {
 	d := du.NewDiskUsage(path)
 	return d.Available()
 }
 
+type diskSpaceEntry struct {
+	available uint64
+	checked   time.Time
+}
+
+const diskSpaceCacheTime = 30 * time.Second
+
+// getCachedDiskSpaceAvailable is getDiskSpaceAvailable for API responses, which checks each directory at
+// most every 30 seconds however many clients are polling.
+func (server *Server) getCachedDiskSpaceAvailable(path string) uint64 {
+	server.diskSpaceLock.Lock()
+	defer server.diskSpaceLock.Unlock()
+	if server.diskSpace == nil {
+		server.diskSpace = map[string]diskSpaceEntry{}
+	}
+	entry, ok := server.diskSpace[path]
+	if !ok || time.Since(entry.checked) > diskSpaceCacheTime {
+		entry = diskSpaceEntry{available: server.getDiskSpaceAvailable(path), checked: time.Now()}
+		server.diskSpace[path] = entry
+	}
+	return entry.available
+}
+
 func (server *Server) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 	log.Printf("New query: %s -  %s", req.Method, req.URL.String())
-	defer server.lock.RUnlock()
-	server.lock.RLock()
+	if strings.HasPrefix(req.URL.Path, "/ui") {
+		serveWebUI(resp, req)
+		return
+	}
+	if !server.authorized(req) {
+		http.Error(resp, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if wantsWebUI(req) {
+		http.Redirect(resp, req, "/ui/", http.StatusFound)
+		return
+	}
+	if strings.HasPrefix(req.URL.Path, "/plots/") {
+		server.servePlot(resp, req)
+		return
+	}
+	switch req.URL.Path {
+	case "/api/state":
+		server.lock.RLock()
+		msg := server.makeMsg(0)
+		server.lock.RUnlock()
+		writeJSON(resp, msg)
+		return
+	case "/api/config":
+		server.serveConfig(resp, req)
+		return
+	case "/api/scheduler":
+		writeJSON(resp, server.scheduler.Entries())
+		return
+	case "/api/diagnostics":
+		server.serveDiagnostics(resp, req)
+		return
+	case "/archive", "/archive/export":
+		collect := func(filter archiveFilter) []archiveRecord {
+			server.lock.RLock()
+			defer server.lock.RUnlock()
+			return exportArchive(map[string][]*ActivePlot{server.hostname(): server.archive}, filter)
+		}
+		if req.URL.Path == "/archive" {
+			serveArchivePage(resp, req, collect)
+		} else {
+			serveArchiveExport(resp, req, collect)
+		}
+		return
+	}
 
 	switch req.Method {
 	case "GET":
-		var msg Msg
-		msg.TargetDirs = map[string]uint64{}
-		msg.TempDirs = map[string]uint64{}
+		server.serveState(resp, req)
+	case "DELETE":
+		// legacy kill of a plot by its Id, guarded like DELETE /plots/{id}
+		if !canModify(resp, server.authToken()) {
+			return
+		}
+		server.lock.RLock()
 		for _, v := range server.active {
-			msg.Actives = append(msg.Actives, v)
+			if v.snapshot().Id == req.RequestURI {
+				v.kill()
+			}
+		}
+		server.lock.RUnlock()
+	}
+}
+
+// hostname returns the name of the plotter, as used in notifications and exports.
+func (server *Server) hostname() string {
+	if server.notifier != nil {
+		return server.notifier.Host
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// authorized checks the request carries the AuthToken of the configuration, if there is one, either as a
+// bearer token or as the token query parameter.
+func (server *Server) authorized(req *http.Request) bool {
+	return checkToken(req, server.authToken())
+}
+
+func (server *Server) authToken() string {
+	if server.config == nil {
+		return ""
+	}
+	server.config.Lock.RLock()
+	defer server.config.Lock.RUnlock()
+	if server.config.CurrentConfig == nil {
+		return ""
+	}
+	return server.config.CurrentConfig.AuthToken
+}
+
+// checkToken reports whether the request carries the token, in the Authorization header or the token query parameter.
+func checkToken(req *http.Request, token string) bool {
+	if len(token) == 0 {
+		return true
+	}
+	given := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if len(given) == 0 {
+		given = req.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// serveState writes the Msg of the server.  Clients which pass the Version and Epoch of their last Msg
+// as the since and epoch query parameters get a delta with only the plots changed since, waiting up to
+// wait seconds for a change.  A full Msg is sent to clients without a valid since, eg. after a restart.
+func (server *Server) serveState(resp http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	since, _ := strconv.ParseUint(query.Get("since"), 10, 64)
+	epoch, _ := strconv.ParseInt(query.Get("epoch"), 10, 64)
+	wait, _ := strconv.Atoi(query.Get("wait"))
+	if epoch != server.epoch || since > server.events.currentSeq() {
+		since = 0
+	}
+	if since > 0 && wait > 0 {
+		start := time.Now()
+		server.events.waitForChange(req.Context(), since, time.Duration(wait)*time.Second)
+		resp.Header().Set(waitHeader, strconv.FormatInt(int64(time.Since(start)/time.Millisecond), 10))
+	}
+
+	server.lock.RLock()
+	msg := server.makeMsg(since)
+	server.lock.RUnlock()
+
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(msg); err == nil {
+		resp.WriteHeader(http.StatusOK)
+		resp.Write(buf.Bytes())
+	} else {
+		resp.WriteHeader(http.StatusInternalServerError)
+		log.Printf("Failed to encode message: %s", err)
+	}
+}
+
+// makeMsg builds the Msg of the server, only including the plots changed after since unless it is 0.
+// Must be called with the server lock held.
+func (server *Server) makeMsg(since uint64) *Msg {
+	msg := &Msg{
+		TargetDirs:    map[string]uint64{},
+		TempDirs:      map[string]uint64{},
+		Status:        server.lastStatus,
+		Version:       server.events.currentSeq(),
+		Epoch:         server.epoch,
+		Delta:         since > 0,
+		ArchiveCutoff: server.archiveCutoff,
+	}
+	// the Msg is encoded after the lock is released, while the plots keep changing
+	for _, v := range server.active {
+		if v.getVersion() > since || since == 0 {
+			msg.Actives = append(msg.Actives, v.snapshot())
+		}
+	}
+	for _, v := range server.archive {
+		if v.getVersion() > since || since == 0 {
+			msg.Archived = append(msg.Archived, v.snapshot())
 		}
-		for _, v := range server.archive {
-			msg.Archived = append(msg.Archived, v)
+	}
+	if server.config.CurrentConfig != nil {
+		for _, dir := range server.config.CurrentConfig.TargetDirectory {
+			msg.TargetDirs[dir] = server.getCachedDiskSpaceAvailable(dir)
+		}
+		for _, dir := range server.config.CurrentConfig.TempDirectory {
+			msg.TempDirs[dir] = server.getCachedDiskSpaceAvailable(dir)
+		}
+	}
+	return msg
+}
+
+// findPlot returns the active or archived plot with the given plot id, or PlotId.
+func (server *Server) findPlot(id string) *ActivePlot {
+	server.lock.RLock()
+	defer server.lock.RUnlock()
+	plotId, _ := strconv.ParseInt(id, 10, 64)
+	for _, plot := range server.active {
+		if plot.snapshot().Id == id || plot.PlotId == plotId {
+			return plot
+		}
+	}
+	for _, plot := range server.archive {
+		if plot.Id == id || plot.PlotId == plotId {
+			return plot
 		}
+	}
+	return nil
+}
+
+// servePlot handles requests for a single plot: DELETE /plots/{id}, /plots/{id}/log and /plots/{id}/events
+func (server *Server) servePlot(resp http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		http.NotFound(resp, req)
+		return
+	}
+	plot := server.findPlot(parts[1])
+	if plot == nil {
+		http.Error(resp, "plot not found", http.StatusNotFound)
+		return
+	}
+	if len(parts) == 2 {
+		if req.Method != "DELETE" {
+			http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+		} else if canModify(resp, server.authToken()) {
+			log.Printf("Killing plot %d on request", plot.PlotId)
+			plot.kill()
+			resp.Header().Set(killedHeader, strconv.FormatInt(plot.PlotId, 10))
+		}
+		return
+	}
+	switch {
+	case parts[2] == "log" && req.Method == "GET":
+		server.servePlotLog(resp, req, plot)
+	case parts[2] == "events" && req.Method == "GET":
+		server.servePlotEvents(resp, req, plot)
+	default:
+		http.NotFound(resp, req)
+	}
+}
+
+// serveConfig returns the configuration (GET) or replaces the configuration file (PUT), which is
+// reloaded by the plotting loop.  The AuthToken is never sent, a configuration without one keeps the
+// current token.
+func (server *Server) serveConfig(resp http.ResponseWriter, req *http.Request) {
+	if !canModify(resp, server.authToken()) {
+		return
+	}
+	switch req.Method {
+	case "GET":
+		var config *Config
+		server.config.Lock.RLock()
 		if server.config.CurrentConfig != nil {
-			for _, dir := range server.config.CurrentConfig.TargetDirectory {
-				msg.TargetDirs[dir] = server.getDiskSpaceAvailable(dir)
-			}
-			for _, dir := range server.config.CurrentConfig.TempDirectory {
-				msg.TempDirs[dir] = server.getDiskSpaceAvailable(dir)
-			}
+			copied := *server.config.CurrentConfig
+			copied.AuthToken = ""
+			config = &copied
+		}
+		server.config.Lock.RUnlock()
+		writeJSON(resp, config)
+	case "PUT":
+		var config Config
+		decoder := json.NewDecoder(req.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&config); err != nil {
+			http.Error(resp, fmt.Sprintf("invalid configuration: %s", err), http.StatusBadRequest)
+			return
 		}
-		msg.Status = server.lastStatus
-		var buf bytes.Buffer
-		enc := gob.NewEncoder(&buf)
-		if err := enc.Encode(msg); err == nil {
+		server.config.Lock.RLock()
+		changed := commandsChanged(server.config.CurrentConfig, &config)
+		if len(config.AuthToken) == 0 && server.config.CurrentConfig != nil {
+			config.AuthToken = server.config.CurrentConfig.AuthToken
+		}
+		server.config.Lock.RUnlock()
+		if len(changed) > 0 {
+			http.Error(resp, fmt.Sprintf("%s can only be changed in the configuration file", strings.Join(changed, ", ")), http.StatusForbidden)
+			return
+		}
+		if err := server.config.SaveConfig(&config); err != nil {
+			http.Error(resp, fmt.Sprintf("failed to save configuration: %s", err), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Configuration file updated on request")
+		resp.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// commandsChanged returns the settings naming commands run by the server which differ in config: the
+// hooks and the paths of the plotter executables.  These are refused over the API, as they would let any
+// holder of the AuthToken run commands on the plotter.
+func commandsChanged(current *Config, config *Config) (changed []string) {
+	if current == nil {
+		current = &Config{}
+	}
+	if config.Hooks != current.Hooks {
+		changed = append(changed, "Hooks")
+	}
+	if config.MadMaxPlotter != current.MadMaxPlotter {
+		changed = append(changed, "MadMaxPlotter")
+	}
+	if config.ChiaRoot != current.ChiaRoot {
+		changed = append(changed, "ChiaRoot")
+	}
+	return changed
+}
+
+// servePlotLog writes the full log of a plot starting at the offset query parameter.  With follow
+// set, the response is kept open and new lines are sent until the plot ends.
+func (server *Server) servePlotLog(resp http.ResponseWriter, req *http.Request, plot *ActivePlot) {
+	if plot.logStore == nil {
+		http.Error(resp, "plot log not available", http.StatusNotFound)
+		return
+	}
+	in, err := plot.logStore.Open(plot.PlotId)
+	if err != nil {
+		http.Error(resp, "plot log not available", http.StatusNotFound)
+		return
+	}
+	defer in.Close()
+	query := req.URL.Query()
+	if offset, err := strconv.ParseInt(query.Get("offset"), 10, 64); err == nil && offset > 0 {
+		if _, err := io.CopyN(ioutil.Discard, in, offset); err != nil {
+			resp.Header().Set("Content-Type", "text/plain; charset=utf-8")
 			resp.WriteHeader(http.StatusOK)
-			resp.Write(buf.Bytes())
-		} else {
-			resp.WriteHeader(http.StatusInternalServerError)
-			log.Printf("Failed to encode message: %s", err)
+			return
 		}
-	case "DELETE":
-		for _, v := range server.active {
-			if v.Id == req.RequestURI {
-				v.process.Kill()
-				v.State = PlotKilled
+	}
+	follow, _ := strconv.ParseBool(query.Get("follow"))
+
+	resp.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	resp.WriteHeader(http.StatusOK)
+	flusher, _ := resp.(http.Flusher)
+	for {
+		running := plot.running()
+		if _, err := io.Copy(resp, in); err != nil {
+			return
+		}
+		if !follow || !running {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-req.Context().Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// servePlotEvents streams the log lines, starting at the offset query parameter, and state changes of
+// a plot as server-sent events until the plot ends.  If the client falls behind and events are dropped,
+// a resync event with the offset of the first missing line ends the stream.
+func (server *Server) servePlotEvents(resp http.ResponseWriter, req *http.Request, plot *ActivePlot) {
+	flusher, ok := resp.(http.Flusher)
+	if !ok || server.events == nil {
+		http.Error(resp, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	// subscribe before reading the log so no line is missed, lines read twice are skipped by offset
+	ch := server.events.subscribe()
+	defer server.events.unsubscribe(ch)
+
+	resp.Header().Set("Content-Type", "text/event-stream")
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.WriteHeader(http.StatusOK)
+
+	offset, _ := strconv.ParseInt(req.URL.Query().Get("offset"), 10, 64)
+	id := plot.snapshot().Id
+	var sent int64
+	if plot.logStore != nil {
+		if in, err := plot.logStore.Open(plot.PlotId); err == nil {
+			reader := bufio.NewReader(in)
+			running := plot.running()
+			for {
+				line, err := reader.ReadString('\n')
+				if len(line) == 0 || (err != nil && running) {
+					// keep a partial last line for the live event
+					break
+				}
+				if sent >= offset {
+					writeEvent(resp, PlotEvent{PlotId: plot.PlotId, Id: id, Kind: PlotEventLog, Line: line, Offset: sent})
+				}
+				sent += int64(len(line))
+			}
+			in.Close()
+		}
+	}
+	plot.lock.RLock()
+	tail, logSize := plot.Tail, plot.logSize
+	state := PlotEvent{PlotId: plot.PlotId, Id: plot.Id, Kind: PlotEventState, State: plot.State, Phase: plot.Phase, Progress: plot.Progress}
+	plot.lock.RUnlock()
+	if sent == 0 {
+		for _, line := range tail {
+			writeEvent(resp, PlotEvent{PlotId: plot.PlotId, Id: state.Id, Kind: PlotEventLog, Line: line, Offset: -1})
+		}
+		sent = logSize
+	}
+	writeEvent(resp, state)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+	for state.State == PlotRunning || state.State == PlotStalled {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(resp, ": keep-alive\n\n")
+		case ev, ok := <-ch:
+			if ok && ev.PlotId != plot.PlotId {
+				continue
+			}
+			if !ok || (ev.Kind == PlotEventLog && ev.Offset > sent) {
+				writeEvent(resp, PlotEvent{PlotId: plot.PlotId, Id: state.Id, Kind: PlotEventResync, Offset: sent})
+				flusher.Flush()
+				return
+			}
+			if ev.Kind == PlotEventLog {
+				if ev.Offset < sent {
+					continue
+				}
+				sent = ev.Offset + int64(len(ev.Line))
+			} else if ev.Kind == PlotEventState {
+				state = ev
+			} else {
+				continue
 			}
+			writeEvent(resp, ev)
 		}
+		flusher.Flush()
 	}
 }
 
+func writeEvent(w io.Writer, ev PlotEvent) {
+	data, _ := json.Marshal(ev)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Kind, data)
+}
+
 type Msg struct {
-	Actives    []*ActivePlot
-	Archived   []*ActivePlot
-	TempDirs   map[string]uint64
-	TargetDirs map[string]uint64
-	Status     string
+	Actives       []*ActivePlot
+	Archived      []*ActivePlot
+	TempDirs      map[string]uint64
+	TargetDirs    map[string]uint64
+	Status        string
+	Version       uint64    // 0 for servers which do not support deltas
+	Epoch         int64     // start time of the server, versions are reset when it changes
+	Delta         bool      // only the plots changed since the requested version are included
+	ArchiveCutoff time.Time // archived plots which ended before it were pruned by the server
+
+	// set by plotng-hub, which answers with the Msg of every plotter it polls
+	Hosts    map[string]*Msg
+	LastSeen time.Time // last successful request to the plotter
+	Latency  time.Duration
+}
+
+// apply returns the Msg resulting from applying delta to msg.  Plots are never modified, so a Msg
+// can be used while the next one is being built.
+func (msg *Msg) apply(delta *Msg) *Msg {
+	if !delta.Delta || msg == nil {
+		return delta
+	}
+	merged := &Msg{
+		TempDirs:      delta.TempDirs,
+		TargetDirs:    delta.TargetDirs,
+		Status:        delta.Status,
+		Version:       delta.Version,
+		Epoch:         delta.Epoch,
+		ArchiveCutoff: delta.ArchiveCutoff,
+		LastSeen:      delta.LastSeen,
+		Latency:       delta.Latency,
+	}
+	changed := map[int64]bool{}
+	for _, plot := range delta.Actives {
+		changed[plot.PlotId] = true
+	}
+	for _, plot := range delta.Archived {
+		changed[plot.PlotId] = true
+	}
+	for _, plot := range msg.Actives {
+		if !changed[plot.PlotId] {
+			merged.Actives = append(merged.Actives, plot)
+		}
+	}
+	merged.Actives = append(merged.Actives, delta.Actives...)
+	for _, plot := range msg.Archived {
+		if !changed[plot.PlotId] && !plot.EndTime.Before(delta.ArchiveCutoff) {
+			merged.Archived = append(merged.Archived, plot)
+		}
+	}
+	merged.Archived = append(merged.Archived, delta.Archived...)
+	// a hub sends a delta of every plotter, plotters it no longer reports are dropped
+	if delta.Hosts != nil {
+		merged.Hosts = map[string]*Msg{}
+		for name, host := range delta.Hosts {
+			merged.Hosts[name] = msg.Hosts[name].apply(host)
+		}
+	}
+	return merged
 }
@@ -1,6 +1,10 @@
 package internal
 
 import (
+	"encoding/gob"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -293,4 +297,114 @@ func TestCanCreateNewPlotParallel(t *testing.T) {
 
 	svr.active[1].Phase = "4/4"
 	checkSuccess(t, svr, now, "target", "plot")
-}
\ No newline at end of file
+}
+
+func TestCheckStalled(t *testing.T) {
+	svr := &Server{
+		config: &PlotConfig{
+			CurrentConfig: &Config{},
+		},
+		active: make(map[int64]*ActivePlot),
+		archive: []*ActivePlot{
+			{
+				State:      PlotFinished,
+				PlotDir:    "plot",
+				StartTime:  initialTime,
+				Phase1Time: initialTime.Add(2 * time.Hour),
+				Phase2Time: initialTime.Add(3 * time.Hour),
+				Phase3Time: initialTime.Add(4 * time.Hour),
+				Phase4Time: initialTime.Add(5 * time.Hour),
+				EndTime:    initialTime.Add(5 * time.Hour),
+			},
+		},
+	}
+	config := svr.config.CurrentConfig
+	quiet := &ActivePlot{State: PlotRunning, Phase: "1/4", PlotDir: "other", StartTime: initialTime, LastLogTime: initialTime}
+	slow := &ActivePlot{State: PlotRunning, Phase: "1/4", PlotDir: "plot", StartTime: initialTime, LastLogTime: initialTime.Add(5 * time.Hour)}
+	ended := &ActivePlot{State: PlotFinished, Phase: "cp", PlotDir: "other", StartTime: initialTime, LastLogTime: initialTime}
+	svr.active[1] = quiet
+	svr.active[2] = slow
+	svr.active[3] = ended
+
+	now := initialTime.Add(5 * time.Hour)
+	svr.checkStalled(config, now)
+	if quiet.State != PlotRunning || slow.State != PlotRunning {
+		t.Fatal("stall detection should be disabled by default")
+	}
+
+	config.StallMinutes = 30
+	svr.checkStalled(config, now)
+	if quiet.State != PlotStalled {
+		t.Error("plot without output should be stalled")
+	}
+	if slow.State != PlotRunning {
+		t.Error("plot with recent output should be running")
+	}
+	if ended.State != PlotFinished {
+		t.Error("plot which ended should not be stalled")
+	}
+
+	config.StallPhaseFactor = 2
+	svr.checkStalled(config, now)
+	if slow.State != PlotStalled {
+		t.Error("plot running phase 1 for 2.5 times the usual duration should be stalled")
+	}
+
+	quiet.LastLogTime = now
+	config.StallPhaseFactor = 3
+	svr.checkStalled(config, now)
+	if quiet.State != PlotRunning || slow.State != PlotRunning {
+		t.Error("plots should have resumed")
+	}
+}
+
+func getMsg(t *testing.T, svr http.Handler, url string) *Msg {
+	t.Helper()
+	resp := httptest.NewRecorder()
+	svr.ServeHTTP(resp, httptest.NewRequest("GET", url, nil))
+	var msg Msg
+	if err := gob.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return &msg
+}
+
+func TestServeStateDelta(t *testing.T) {
+	svr := &Server{
+		config:     &PlotConfig{},
+		active:     make(map[int64]*ActivePlot),
+		events:     newEventHub(),
+		epoch:      1,
+		lastStatus: "Creating plot",
+	}
+	for plotId := int64(1); plotId <= 2; plotId++ {
+		plot := &ActivePlot{PlotId: plotId, State: PlotRunning, Phase: "1/4", events: svr.events}
+		plot.publishState()
+		svr.active[plotId] = plot
+	}
+
+	full := getMsg(t, svr, "/")
+	if full.Delta || len(full.Actives) != 2 || full.Version != 2 || full.Epoch != 1 {
+		t.Fatalf("unexpected full msg: %+v", full)
+	}
+
+	svr.active[2].Phase = "2/4"
+	svr.active[2].publishState()
+	delta := getMsg(t, svr, fmt.Sprintf("/?since=%d&epoch=1&wait=1", full.Version))
+	if !delta.Delta || len(delta.Actives) != 1 || delta.Actives[0].PlotId != 2 || delta.Version != 3 {
+		t.Fatalf("unexpected delta: %+v", delta)
+	}
+	merged := full.apply(delta)
+	if len(merged.Actives) != 2 || merged.Version != 3 || merged.Status != "Creating plot" {
+		t.Fatalf("unexpected merged msg: %+v", merged)
+	}
+	for _, plot := range merged.Actives {
+		if plot.PlotId == 2 && plot.Phase != "2/4" {
+			t.Errorf("delta not applied: %+v", plot)
+		}
+	}
+
+	if msg := getMsg(t, svr, "/?since=3&epoch=2"); msg.Delta || len(msg.Actives) != 2 {
+		t.Errorf("full msg expected after a server restart: %+v", msg)
+	}
+}
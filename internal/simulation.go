@@ -0,0 +1,281 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clock tells the time of the server, which runs Speed times faster than real time in a simulation.  A
+// nil clock is the real time.
+type clock struct {
+	start  time.Time // real time the clock was started
+	origin time.Time // time told at start
+	speed  float64
+}
+
+func newClock(speed float64, now time.Time) *clock {
+	return &clock{start: now, origin: now, speed: speed}
+}
+
+func (c *clock) Now() time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.origin.Add(time.Duration(float64(time.Since(c.start)) * c.speed))
+}
+
+// Real returns the real duration of d on the clock.
+func (c *clock) Real(d time.Duration) time.Duration {
+	if c == nil {
+		return d
+	}
+	return time.Duration(float64(d) / c.speed)
+}
+
+// defaultSimulationModel is the duration of each phase of a k32 plot made alone on a temp directory.
+var defaultSimulationModel = [phaseCount]time.Duration{
+	3 * time.Hour,
+	80 * time.Minute,
+	150 * time.Minute,
+	10 * time.Minute,
+	10 * time.Minute,
+}
+
+// simulationContention slows the phases of a plot down by 25% for every other plot using the same temp
+// directory when it starts.
+const simulationContention = 0.25
+
+// Simulation replaces the plotter by a fake one, whose phases take the durations of a model or of
+// replayed archived plots, on a clock running Speed times faster than real time.  The destination
+// directories are filled by the plots made, and the throughput is reported every simulated day.
+type Simulation struct {
+	Speed     float64                   // how much faster than real time the clock runs, default 60
+	Duration  time.Duration             // simulated time after which the server stops, 0 to run forever
+	DiskSpace uint64                    // free space of each destination directory at start, 0 for its real free space
+	Replay    string                    // archive exported as JSON (/archive/export?format=json) whose phase durations are replayed
+	Model     [phaseCount]time.Duration // phase durations when not replaying, defaultSimulationModel if missing
+
+	clock      *clock
+	lock       sync.Mutex
+	records    []archiveRecord
+	next       int
+	initial    map[string]uint64 // free space of each destination directory at start
+	used       map[string]uint64 // space taken by the plots made
+	fullAt     map[string]time.Time
+	finished   int
+	bytes      uint64
+	lastReport time.Time
+}
+
+// start loads the replayed archive and starts the clock.
+func (sim *Simulation) start(now time.Time) error {
+	if sim.Speed <= 0 {
+		sim.Speed = 60
+	}
+	if sim.Model == ([phaseCount]time.Duration{}) {
+		sim.Model = defaultSimulationModel
+	}
+	if len(sim.Replay) > 0 {
+		data, err := ioutil.ReadFile(sim.Replay)
+		if err != nil {
+			return err
+		}
+		var records []archiveRecord
+		if err := json.Unmarshal(data, &records); err != nil {
+			return fmt.Errorf("invalid archive [%s]: %w", sim.Replay, err)
+		}
+		for _, record := range records {
+			if record.Phase1Secs > 0 && record.Phase2Secs > 0 && record.Phase3Secs > 0 && record.Phase4Secs > 0 {
+				sim.records = append(sim.records, record)
+			}
+		}
+		if len(sim.records) == 0 {
+			return fmt.Errorf("no finished plot to replay in [%s]", sim.Replay)
+		}
+	}
+	sim.initial = map[string]uint64{}
+	sim.used = map[string]uint64{}
+	sim.fullAt = map[string]time.Time{}
+	sim.clock = newClock(sim.Speed, now)
+	sim.lastReport = now
+	return nil
+}
+
+// durations returns the phase durations of a plot using plotDir while others plots use it: the next
+// replayed plot which used plotDir (or the next one if none did), or the model slowed down by the others.
+func (sim *Simulation) durations(plotDir string, others int) (durations [phaseCount]time.Duration) {
+	sim.lock.Lock()
+	defer sim.lock.Unlock()
+	if len(sim.records) > 0 {
+		index := sim.next % len(sim.records)
+		for i := 0; i < len(sim.records); i++ {
+			if j := (sim.next + i) % len(sim.records); sim.records[j].PlotDir == plotDir {
+				index = j
+				break
+			}
+		}
+		sim.next = index + 1
+		record := sim.records[index]
+		for phase, secs := range []int64{record.Phase1Secs, record.Phase2Secs, record.Phase3Secs, record.Phase4Secs, record.CopySecs} {
+			durations[phase] = time.Duration(secs) * time.Second
+		}
+		return
+	}
+	factor := 1 + simulationContention*float64(others)
+	for phase, d := range sim.Model {
+		durations[phase] = time.Duration(float64(d) * factor)
+	}
+	return
+}
+
+// available returns the free space of a destination directory, less the plots made in it.
+func (sim *Simulation) available(path string, real func(string) uint64) uint64 {
+	sim.lock.Lock()
+	defer sim.lock.Unlock()
+	initial, ok := sim.initial[path]
+	if !ok {
+		initial = sim.DiskSpace
+		if initial == 0 {
+			initial = real(path)
+		}
+		sim.initial[path] = initial
+	}
+	if used := sim.used[path]; used < initial {
+		return initial - used
+	}
+	return 0
+}
+
+// addPlot records a plot made in a destination directory, and when the directory was full.
+func (sim *Simulation) addPlot(path string, size uint64, now time.Time, real func(string) uint64) {
+	available := sim.available(path, real)
+	sim.lock.Lock()
+	defer sim.lock.Unlock()
+	sim.used[path] += size
+	sim.finished++
+	sim.bytes += size
+	if _, ok := sim.fullAt[path]; !ok && available < size+PLOT_SIZE {
+		sim.fullAt[path] = now
+	}
+}
+
+// report prints the throughput and disk usage every simulated day, and returns true once the simulation
+// is over.
+func (sim *Simulation) report(now time.Time) bool {
+	over := sim.Duration > 0 && now.Sub(sim.clock.origin) >= sim.Duration
+	if !over && now.Sub(sim.lastReport) < 24*time.Hour {
+		return false
+	}
+	sim.lastReport = now
+	fmt.Print(sim.summary(now))
+	return over
+}
+
+func (sim *Simulation) summary(now time.Time) string {
+	sim.lock.Lock()
+	defer sim.lock.Unlock()
+	elapsed := now.Sub(sim.clock.origin)
+	days := elapsed.Hours() / 24
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "Simulation after %s: %d plots finished", DurationString(elapsed), sim.finished)
+	if days > 0 {
+		fmt.Fprintf(&buf, ", %.1f plots/day, %s/day", float64(sim.finished)/days, SpaceString(uint64(float64(sim.bytes)/days)))
+	}
+	buf.WriteString("\n")
+	var dirs []string
+	for dir := range sim.initial {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		available := uint64(0)
+		if sim.used[dir] < sim.initial[dir] {
+			available = sim.initial[dir] - sim.used[dir]
+		}
+		fmt.Fprintf(&buf, "\t%s: %s used, %s available", dir, SpaceString(sim.used[dir]), SpaceString(available))
+		if fullAt, ok := sim.fullAt[dir]; ok {
+			fmt.Fprintf(&buf, ", full after %s", DurationString(fullAt.Sub(sim.clock.origin)))
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// simulatedPlot is the fake plotter of a plot in a simulation.
+type simulatedPlot struct {
+	durations [phaseCount]time.Duration
+	stop      chan struct{}
+	once      sync.Once
+}
+
+func (sp *simulatedPlot) kill() {
+	sp.once.Do(func() { close(sp.stop) })
+}
+
+// simulatedLog is the log of each phase of the fake plotter, written over the duration of the phase in
+// the format of the chia plotter.
+var simulatedLog = [phaseCount][]string{
+	{"Starting phase 1/4: Forward Propagation into tmp files", "Computing table 1", "Computing table 2", "Computing table 3",
+		"Computing table 4", "Computing table 5", "Computing table 6", "Computing table 7"},
+	{"Starting phase 2/4: Backpropagation into tmp files", "Backpropagating on table 7", "Backpropagating on table 6",
+		"Backpropagating on table 5", "Backpropagating on table 4", "Backpropagating on table 3", "Backpropagating on table 2"},
+	{"Starting phase 3/4: Compression from tmp files into final file", "Compressing tables 1 and 2", "Compressing tables 2 and 3",
+		"Compressing tables 3 and 4", "Compressing tables 4 and 5", "Compressing tables 5 and 6", "Compressing tables 6 and 7"},
+	{"Starting phase 4/4: Write Checkpoint tables into final file", "Write checkpoint tables"},
+	{"Copied final file"},
+}
+
+// write writes the log of the fake plotter, and returns false if the plot was killed.
+func (sp *simulatedPlot) write(ap *ActivePlot, w io.Writer) bool {
+	fmt.Fprintf(w, "ID: %064x\n", ap.PlotId)
+	for phase, lines := range simulatedLog {
+		step := sp.durations[phase] / time.Duration(len(lines))
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+			select {
+			case <-sp.stop:
+				return false
+			case <-time.After(ap.clock.Real(step)):
+			}
+		}
+	}
+	fmt.Fprintf(w, "Renamed final file from \"%s\" to \"%s\"\n",
+		fmt.Sprintf("%s/plot-%064x.plot.tmp", ap.TargetDir, ap.PlotId), fmt.Sprintf("%s/plot-%064x.plot", ap.TargetDir, ap.PlotId))
+	return true
+}
+
+// runSimulated is RunPlot for the fake plotter of a simulation.
+func (ap *ActivePlot) runSimulated(sim *Simulation, real func(string) uint64) {
+	ap.lock.Lock()
+	ap.StartTime = ap.clock.Now()
+	ap.CommandLine = "simulated plotter"
+	ap.lock.Unlock()
+	ap.setState(PlotRunning)
+	r, w := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		ap.processLogs(r)
+		close(done)
+	}()
+	finished := ap.simulated.write(ap, w)
+	w.Close()
+	<-done
+	if !finished {
+		log.Printf("Plot [%s] Killed", ap.Id)
+		ap.finish(PlotKilled)
+		return
+	}
+	size := plotFileSize(ap.PlotSize)
+	ap.lock.Lock()
+	ap.FinalSize = size
+	ap.lock.Unlock()
+	sim.addPlot(ap.TargetDir, size, ap.clock.Now(), real)
+	ap.finish(PlotFinished)
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimulationDurations(t *testing.T) {
+	sim := &Simulation{}
+	if err := sim.start(initialTime); err != nil {
+		t.Fatal(err)
+	}
+	if d := sim.durations("/tmp1", 0); d != defaultSimulationModel {
+		t.Errorf("expected the model, got %v", d)
+	}
+	if d := sim.durations("/tmp1", 2); d[0] != 3*defaultSimulationModel[0]/2 {
+		t.Errorf("expected phase 1 50%% longer with 2 other plots, got %s", d[0])
+	}
+
+	records := []archiveRecord{
+		{PlotDir: "/tmp1", Phase1Secs: 100, Phase2Secs: 50, Phase3Secs: 80, Phase4Secs: 10, CopySecs: 5},
+		{PlotDir: "/tmp2", Phase1Secs: 200, Phase2Secs: 60, Phase3Secs: 90, Phase4Secs: 20, CopySecs: 5},
+		{PlotDir: "/tmp1", Phase1Secs: 300},
+	}
+	data, _ := json.Marshal(records)
+	path := filepath.Join(t.TempDir(), "archive.json")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	sim = &Simulation{Replay: path}
+	if err := sim.start(initialTime); err != nil {
+		t.Fatal(err)
+	}
+	if len(sim.records) != 2 {
+		t.Fatalf("expected the 2 finished plots, got %d", len(sim.records))
+	}
+	for _, c := range []struct {
+		plotDir  string
+		expected time.Duration
+	}{
+		{"/tmp2", 200},
+		{"/tmp2", 200},
+		{"/tmp1", 100},
+		{"/tmp3", 200}, // the next plot for an unknown temp directory
+	} {
+		if d := sim.durations(c.plotDir, 5); d[0] != c.expected*time.Second || d[4] != 5*time.Second {
+			t.Errorf("expected %d seconds for %s, got %v", c.expected, c.plotDir, d)
+		}
+	}
+}
+
+func TestSimulatedPlot(t *testing.T) {
+	sim := &Simulation{Speed: 100000, DiskSpace: 300 * GB}
+	if err := sim.start(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	svr := &Server{config: &PlotConfig{CurrentConfig: &Config{}}, active: map[int64]*ActivePlot{}, Simulation: sim, clock: sim.clock}
+	real := func(string) uint64 { t.Fatal("unexpected real disk space"); return 0 }
+
+	model := [phaseCount]time.Duration{time.Hour, time.Hour, time.Hour, time.Hour, time.Hour}
+	for i := 0; i < 2; i++ {
+		plot := &ActivePlot{PlotId: int64(i), PlotDir: "/tmp1", TargetDir: "/dest1", clock: sim.clock,
+			simulated: &simulatedPlot{durations: model, stop: make(chan struct{})}}
+		plot.runSimulated(sim, real)
+		if plot.State != PlotFinished || plot.getCurrentPhase() != 5 || plot.Id != "0000000000000000000000000000000000000000000000000000000000000000"[:63]+string(rune('0'+i)) {
+			t.Fatalf("unexpected plot %s state %d phase %s", plot.Id, plot.State, plot.Phase)
+		}
+		for phase := 1; phase <= phaseCount; phase++ {
+			if d := plot.getPhaseTime(phase).Sub(plot.getPhaseTime(phase - 1)); d < time.Hour || d > 2*time.Hour {
+				t.Errorf("phase %d took %s", phase, d)
+			}
+		}
+	}
+	if available := svr.getDiskSpaceAvailable("/dest1"); available != 300*GB-2*plotFileSize(32) {
+		t.Errorf("unexpected available space %s", SpaceString(available))
+	}
+	summary := sim.summary(sim.clock.Now())
+	if !strings.Contains(summary, "2 plots finished") || !strings.Contains(summary, "/dest1: 202 GiB used, 97 GiB available, full after") {
+		t.Errorf("unexpected summary\n%s", summary)
+	}
+
+	plot := &ActivePlot{PlotId: 3, PlotDir: "/tmp1", TargetDir: "/dest1", clock: sim.clock,
+		simulated: &simulatedPlot{durations: model, stop: make(chan struct{})}}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		plot.kill()
+	}()
+	plot.runSimulated(sim, real)
+	if plot.State != PlotKilled || sim.finished != 2 {
+		t.Errorf("expected the plot killed, got state %d and %d plots finished", plot.State, sim.finished)
+	}
+}
@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sparklineLevels are the characters of a sparkline, from the lowest to the highest value.
+const sparklineLevels = "_.-=+*#@"
+
+// plotFileSize returns the approximate size of a finished plot of size k (k32 when 0).
+func plotFileSize(k int) uint64 {
+	if k == 0 {
+		k = 32
+	}
+	return uint64(float64(2*k+1) * math.Pow(2, float64(k-1)) * 0.78)
+}
+
+// throughput is the number and total size of the plots finished in a period.
+type throughput struct {
+	Plots int
+	Bytes uint64
+	Days  float64
+}
+
+func (tp throughput) PlotsPerDay() float64 {
+	return float64(tp.Plots) / tp.Days
+}
+
+func (tp throughput) TBPerDay() float64 {
+	return float64(tp.Bytes) / float64(TB) / tp.Days
+}
+
+// finishedThroughput counts the plots of archive finished in the period before now.
+func finishedThroughput(archive []*ActivePlot, now time.Time, period time.Duration) throughput {
+	tp := throughput{Days: float64(period) / float64(24*time.Hour)}
+	for _, plot := range archive {
+		if plot.State == PlotFinished && plot.EndTime.After(now.Add(-period)) && !plot.EndTime.After(now) {
+			tp.Plots++
+			tp.Bytes += plotFileSize(plot.PlotSize)
+		}
+	}
+	return tp
+}
+
+// plotsPerDay counts the plots of archive finished on each of the last days before now, the oldest first.
+func plotsPerDay(archive []*ActivePlot, now time.Time, days int) []int {
+	counts := make([]int, days)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for _, plot := range archive {
+		if plot.State != PlotFinished || plot.EndTime.After(now) {
+			continue
+		}
+		end := plot.EndTime.In(now.Location())
+		day := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, now.Location())
+		// days are counted by date rather than 24h, as days with a DST change are shorter or longer
+		age := int(math.Round(float64(today.Sub(day)) / float64(24*time.Hour)))
+		if age >= 0 && age < days {
+			counts[days-1-age]++
+		}
+	}
+	return counts
+}
+
+// sparkline draws the values with one character each, scaled to the largest value.
+func sparkline(values []int) string {
+	max := 0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	var sb strings.Builder
+	for _, v := range values {
+		level := 0
+		if max > 0 {
+			level = v * (len(sparklineLevels) - 1) / max
+		}
+		sb.WriteByte(sparklineLevels[level])
+	}
+	return sb.String()
+}
+
+// phasePercentiles returns the given percentiles of the durations of each phase of the finished plots.
+func phasePercentiles(archive []*ActivePlot, percentiles []float64) (result [phaseCount][]time.Duration, count int) {
+	var durations [phaseCount][]time.Duration
+	for _, plot := range archive {
+		if plot.State != PlotFinished {
+			continue
+		}
+		phases := plot.phaseDurations()
+		for i := range phases {
+			durations[i] = append(durations[i], phases[i])
+		}
+		count++
+	}
+	for i := range durations {
+		sort.Slice(durations[i], func(a, b int) bool { return durations[i][a] < durations[i][b] })
+		for _, p := range percentiles {
+			result[i] = append(result[i], percentile(durations[i], p))
+		}
+	}
+	return
+}
+
+// percentile returns the p-th percentile (0-100) of sorted durations, using the nearest rank.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	} else if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// tempDirStats summarises the plots made in a temp directory of a host.
+type tempDirStats struct {
+	Host     string
+	PlotDir  string
+	Count    int
+	Failed   int
+	AvgTotal time.Duration
+}
+
+// rankTempDirs returns the temp directories of every host with finished plots, the fastest first.
+func rankTempDirs(archives map[string][]*ActivePlot) []tempDirStats {
+	stats := map[string]*tempDirStats{}
+	for host, archive := range archives {
+		for _, plot := range archive {
+			key := host + "||" + plot.PlotDir
+			s, ok := stats[key]
+			if !ok {
+				s = &tempDirStats{Host: host, PlotDir: plot.PlotDir}
+				stats[key] = s
+			}
+			switch plot.State {
+			case PlotFinished:
+				s.AvgTotal += plot.EndTime.Sub(plot.StartTime)
+				s.Count++
+			case PlotError, PlotKilled:
+				s.Failed++
+			}
+		}
+	}
+	var ranked []tempDirStats
+	for _, s := range stats {
+		if s.Count > 0 {
+			s.AvgTotal /= time.Duration(s.Count)
+			ranked = append(ranked, *s)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].AvgTotal != ranked[j].AvgTotal {
+			return ranked[i].AvgTotal < ranked[j].AvgTotal
+		}
+		return ranked[i].Host+ranked[i].PlotDir < ranked[j].Host+ranked[j].PlotDir
+	})
+	return ranked
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlotStats(t *testing.T) {
+	now := time.Date(2021, 6, 10, 12, 0, 0, 0, time.Local)
+	finished := func(end time.Time, hours int, plotDir string) *ActivePlot {
+		start := end.Add(-time.Duration(hours) * time.Hour)
+		return &ActivePlot{
+			State: PlotFinished, PlotDir: plotDir, StartTime: start, EndTime: end,
+			Phase1Time: start.Add(time.Duration(hours) * time.Hour * 4 / 10),
+			Phase2Time: start.Add(time.Duration(hours) * time.Hour / 2),
+			Phase3Time: start.Add(time.Duration(hours) * time.Hour * 3 / 4),
+			Phase4Time: start.Add(time.Duration(hours) * time.Hour * 9 / 10),
+		}
+	}
+	archive := []*ActivePlot{
+		finished(now.Add(-time.Hour), 10, "/tmp1"),
+		finished(now.Add(-2*time.Hour), 8, "/tmp1"),
+		finished(now.Add(-50*time.Hour), 12, "/tmp2"),
+		finished(now.Add(-10*24*time.Hour), 12, "/tmp2"),
+		{State: PlotError, PlotDir: "/tmp2", StartTime: now.Add(-5 * time.Hour), EndTime: now.Add(-4 * time.Hour)},
+	}
+
+	day := finishedThroughput(archive, now, 24*time.Hour)
+	if day.Plots != 2 || day.PlotsPerDay() != 2 || day.Bytes != 2*plotFileSize(32) {
+		t.Errorf("unexpected 24h throughput: %+v", day)
+	}
+	if week := finishedThroughput(archive, now, 7*24*time.Hour); week.Plots != 3 {
+		t.Errorf("unexpected 7d throughput: %+v", week)
+	}
+	if size := plotFileSize(0); size < 100*GB || size > 110*GB {
+		t.Errorf("unexpected k32 size: %d GiB", size/GB)
+	}
+
+	perDay := plotsPerDay(archive, now, 14)
+	if perDay[13] != 2 || perDay[11] != 1 || perDay[3] != 1 {
+		t.Errorf("unexpected plots per day: %v", perDay)
+	}
+	if line := sparkline([]int{0, 1, 2, 4, 7}); line != "_.-+@" {
+		t.Errorf("unexpected sparkline: %q", line)
+	}
+
+	percentiles, count := phasePercentiles(archive, []float64{0, 50, 100})
+	if count != 4 || percentiles[0][0] > percentiles[0][1] || percentiles[0][1] > percentiles[0][2] {
+		t.Errorf("unexpected percentiles (%d plots): %v", count, percentiles)
+	}
+	if p := percentile([]time.Duration{1, 2, 3, 4}, 50); p != 2 {
+		t.Errorf("expected median 2, got %d", p)
+	}
+
+	ranked := rankTempDirs(map[string][]*ActivePlot{"plotter1": archive})
+	if len(ranked) != 2 || ranked[0].PlotDir != "/tmp1" || ranked[0].AvgTotal != 9*time.Hour || ranked[1].Failed != 1 {
+		t.Errorf("unexpected ranking: %+v", ranked)
+	}
+}
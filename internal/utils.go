@@ -24,3 +24,21 @@ func SpaceString(s uint64) string {
 	}
 	return fmt.Sprintf("%d GiB", s / GB)
 }
+
+// AgoString describes how long ago t was, eg. "5 min ago".
+func AgoString(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%d min ago", d/time.Minute)
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%d hours ago", d/time.Hour)
+	default:
+		return fmt.Sprintf("%d days ago", d/(24*time.Hour))
+	}
+}
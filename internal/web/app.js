@@ -0,0 +1,309 @@
+'use strict';
+
+// Web dashboard of plotng-server and plotng-hub, showing the same views as plotng-client.
+
+const GB = 1024 * 1024 * 1024;
+const PLOT_SIZE = 105 * GB;
+const STATES = ['Running', 'Errored', 'Finished', 'Killed', 'Stalled'];
+const REFRESH_INTERVAL = 5000;
+
+let token = localStorage.getItem('plotng-token') || '';
+let hosts = [];
+let logSource = null;
+let selected = '';
+
+function headers() {
+    return token ? {'Authorization': 'Bearer ' + token} : {};
+}
+
+function request(path, options) {
+    options = options || {};
+    options.headers = Object.assign(headers(), options.headers || {});
+    return fetch(path, options).then(resp => {
+        if (!resp.ok) {
+            return resp.text().then(text => {
+                throw new Error(resp.status + ': ' + text.trim());
+            });
+        }
+        return resp;
+    });
+}
+
+function setStatus(text, error) {
+    const status = document.getElementById('status');
+    status.textContent = text;
+    status.className = error ? 'error' : '';
+}
+
+function isZero(time) {
+    return !time || time.startsWith('0001-');
+}
+
+function formatTime(time) {
+    return isZero(time) ? '' : new Date(time).toLocaleString();
+}
+
+function formatDuration(ms) {
+    if (ms <= 0) {
+        return '';
+    }
+    const minutes = Math.floor(ms / 60000);
+    return Math.floor(minutes / 60) + 'h' + String(minutes % 60).padStart(2, '0') + 'm';
+}
+
+function plotDuration(plot) {
+    const end = isZero(plot.EndTime) ? Date.now() : new Date(plot.EndTime).getTime();
+    return formatDuration(end - new Date(plot.StartTime).getTime());
+}
+
+function formatSpace(bytes) {
+    return (bytes / GB).toFixed(0) + ' GB';
+}
+
+function shortId(plot) {
+    return plot.Id ? plot.Id.substring(0, 12) : String(plot.PlotId);
+}
+
+function row(tbody, cells, className) {
+    const tr = document.createElement('tr');
+    if (className) {
+        tr.className = className;
+    }
+    for (const cell of cells) {
+        const td = document.createElement('td');
+        if (cell instanceof Node) {
+            td.appendChild(cell);
+        } else {
+            td.textContent = cell;
+        }
+        tr.appendChild(td);
+    }
+    tbody.appendChild(tr);
+    return tr;
+}
+
+function stateCell(state) {
+    const span = document.createElement('span');
+    span.textContent = STATES[state] || 'Unknown';
+    span.className = STATES[state] || '';
+    return span;
+}
+
+// hostsOf returns the plotters of a state, the hub answers with a Msg for every plotter.
+function hostsOf(state) {
+    if (state.Hosts) {
+        return Object.keys(state.Hosts).sort().map(name => ({
+            name: name,
+            prefix: '/hosts/' + encodeURIComponent(name),
+            msg: state.Hosts[name],
+        }));
+    }
+    return [{name: location.host, prefix: '', msg: state}];
+}
+
+function refresh() {
+    request('/api/state').then(resp => resp.json()).then(state => {
+        hosts = hostsOf(state);
+        setStatus(state.Status || '');
+        draw();
+    }).catch(err => setStatus('Failed to load state: ' + err.message, true));
+}
+
+function draw() {
+    drawActive();
+    drawDirs();
+    drawArchive();
+    drawHosts();
+    drawConfigHosts();
+}
+
+function drawActive() {
+    const tbody = document.querySelector('#active tbody');
+    tbody.textContent = '';
+    for (const host of hosts) {
+        const plots = (host.msg.Actives || []).slice().sort((a, b) => a.PlotId - b.PlotId);
+        for (const plot of plots) {
+            const kill = document.createElement('button');
+            kill.textContent = 'Kill';
+            kill.onclick = event => {
+                event.stopPropagation();
+                killPlot(host, plot);
+            };
+            const key = host.name + '/' + plot.PlotId;
+            const tr = row(tbody, [host.name, shortId(plot), stateCell(plot.State), plot.Phase, plot.Progress,
+                formatTime(plot.StartTime), plotDuration(plot), plot.PlotDir, plot.TargetDir, kill],
+                key === selected ? 'selected' : '');
+            tr.onclick = () => showLog(host, plot, true);
+        }
+    }
+}
+
+function countPlots(host, field, dir) {
+    return (host.msg.Actives || []).filter(plot => plot[field] === dir).length;
+}
+
+function drawDirs() {
+    const temp = document.getElementById('temp-dirs');
+    const target = document.getElementById('target-dirs');
+    temp.textContent = '';
+    target.textContent = '';
+    for (const host of hosts) {
+        for (const dir of Object.keys(host.msg.TempDirs || {}).sort()) {
+            row(temp, [host.name, dir, countPlots(host, 'PlotDir', dir), formatSpace(host.msg.TempDirs[dir])]);
+        }
+        for (const dir of Object.keys(host.msg.TargetDirs || {}).sort()) {
+            const available = host.msg.TargetDirs[dir];
+            row(target, [host.name, dir, countPlots(host, 'TargetDir', dir), formatSpace(available), Math.floor(available / PLOT_SIZE)]);
+        }
+    }
+}
+
+function drawArchive() {
+    const tbody = document.querySelector('#archive tbody');
+    tbody.textContent = '';
+    const plots = [];
+    for (const host of hosts) {
+        for (const plot of host.msg.Archived || []) {
+            plots.push({host: host, plot: plot});
+        }
+    }
+    plots.sort((a, b) => b.plot.PlotId - a.plot.PlotId);
+    for (const entry of plots) {
+        const plot = entry.plot;
+        const key = entry.host.name + '/' + plot.PlotId;
+        const tr = row(tbody, [entry.host.name, shortId(plot), stateCell(plot.State), formatTime(plot.StartTime),
+            formatTime(plot.EndTime), plotDuration(plot), plot.PlotDir, plot.TargetDir], key === selected ? 'selected' : '');
+        tr.onclick = () => showLog(entry.host, plot, false);
+    }
+}
+
+function drawHosts() {
+    const tbody = document.querySelector('#hosts tbody');
+    tbody.textContent = '';
+    for (const host of hosts) {
+        row(tbody, [host.name, host.msg.Status || '', (host.msg.Actives || []).length, (host.msg.Archived || []).length,
+            formatTime(host.msg.LastSeen)]);
+    }
+}
+
+function drawConfigHosts() {
+    const select = document.getElementById('config-host');
+    const current = select.value;
+    select.textContent = '';
+    for (const host of hosts) {
+        const option = document.createElement('option');
+        option.value = host.prefix;
+        option.textContent = host.name;
+        select.appendChild(option);
+    }
+    if (current) {
+        select.value = current;
+    }
+}
+
+function killPlot(host, plot) {
+    if (!confirm('Kill plot ' + shortId(plot) + ' on ' + host.name + '?')) {
+        return;
+    }
+    request(host.prefix + '/plots/' + plot.PlotId, {method: 'DELETE'})
+        .then(() => {
+            setStatus('Killed plot ' + shortId(plot));
+            refresh();
+        })
+        .catch(err => setStatus('Failed to kill plot: ' + err.message, true));
+}
+
+// showLog shows the full log of a plot, following running plots until they end.
+function showLog(host, plot, follow) {
+    if (logSource) {
+        logSource.close();
+        logSource = null;
+    }
+    selected = host.name + '/' + plot.PlotId;
+    draw();
+    const title = document.getElementById('log-title');
+    const text = document.getElementById('log-text');
+    document.getElementById('log').hidden = false;
+    title.textContent = 'Log (' + shortId(plot) + ')';
+    text.textContent = '';
+
+    const path = host.prefix + '/plots/' + plot.PlotId;
+    if (!follow) {
+        request(path + '/log').then(resp => resp.text()).then(log => {
+            text.textContent = log;
+            text.scrollTop = text.scrollHeight;
+        }).catch(err => text.textContent = 'Log not available: ' + err.message);
+        return;
+    }
+    // EventSource cannot set headers, the server also accepts the token as a query parameter
+    const source = new EventSource(path + '/events' + (token ? '?token=' + encodeURIComponent(token) : ''));
+    let offset = -1;
+    source.addEventListener('log', event => {
+        const ev = JSON.parse(event.data);
+        if (ev.Offset >= 0 && ev.Offset <= offset) {
+            return;
+        }
+        offset = ev.Offset;
+        const atEnd = text.scrollTop + text.clientHeight >= text.scrollHeight - 4;
+        text.textContent += ev.Line;
+        if (atEnd) {
+            text.scrollTop = text.scrollHeight;
+        }
+    });
+    source.addEventListener('state', event => {
+        const ev = JSON.parse(event.data);
+        title.textContent = 'Log (' + shortId(plot) + ') - ' + (STATES[ev.State] || '') + ' ' + ev.Phase + ' ' + ev.Progress;
+    });
+    // the server dropped events for this stream, start over with the full log
+    source.addEventListener('resync', () => showLog(host, plot, follow));
+    // the stream ends with the plot, do not let the browser reconnect and replay the log
+    source.onerror = () => source.close();
+    logSource = source;
+}
+
+function selectedConfigHost() {
+    return document.getElementById('config-host').value;
+}
+
+function loadConfig() {
+    request(selectedConfigHost() + '/api/config').then(resp => resp.json()).then(config => {
+        document.getElementById('config-text').value = JSON.stringify(config, null, 2);
+        setStatus('Configuration loaded');
+    }).catch(err => setStatus('Failed to load configuration: ' + err.message, true));
+}
+
+function saveConfig() {
+    const text = document.getElementById('config-text').value;
+    try {
+        JSON.parse(text);
+    } catch (err) {
+        setStatus('Invalid JSON: ' + err.message, true);
+        return;
+    }
+    request(selectedConfigHost() + '/api/config', {method: 'PUT', body: text, headers: {'Content-Type': 'application/json'}})
+        .then(() => setStatus('Configuration saved, it is applied within a minute'))
+        .catch(err => setStatus('Failed to save configuration: ' + err.message, true));
+}
+
+function showView(name) {
+    for (const button of document.querySelectorAll('nav button')) {
+        button.classList.toggle('selected', button.dataset.view === name);
+    }
+    for (const view of document.querySelectorAll('.view')) {
+        view.hidden = view.id !== name;
+    }
+}
+
+document.querySelectorAll('nav button').forEach(button => button.onclick = () => showView(button.dataset.view));
+document.getElementById('token').value = token;
+document.getElementById('token-form').onsubmit = event => {
+    event.preventDefault();
+    token = document.getElementById('token').value;
+    localStorage.setItem('plotng-token', token);
+    refresh();
+};
+document.getElementById('config-load').onclick = loadConfig;
+document.getElementById('config-save').onclick = saveConfig;
+
+refresh();
+setInterval(refresh, REFRESH_INTERVAL);
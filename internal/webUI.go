@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// The web dashboard is embedded so plotng-server and plotng-hub stay single binaries.
+//
+//go:embed web
+var webAssets embed.FS
+
+// serveWebUI serves the web dashboard under /ui/.  The assets are public, the API calls made by the
+// dashboard need the token when one is configured.
+func serveWebUI(resp http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/ui" {
+		http.Redirect(resp, req, "/ui/", http.StatusMovedPermanently)
+		return
+	}
+	assets, err := fs.Sub(webAssets, "web")
+	if err != nil {
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.StripPrefix("/ui/", http.FileServer(http.FS(assets))).ServeHTTP(resp, req)
+}
+
+// wantsWebUI reports whether the request comes from a browser, rather than plotng-client.
+func wantsWebUI(req *http.Request) bool {
+	return req.Method == "GET" && req.URL.Path == "/" && strings.Contains(req.Header.Get("Accept"), "text/html")
+}
+
+// canModify reports whether the request may kill plots or change the configuration, which requires a
+// token to be configured.  The token itself is checked before the request is routed.
+func canModify(resp http.ResponseWriter, token string) bool {
+	if len(token) == 0 {
+		http.Error(resp, "changes are disabled until an AuthToken is configured", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func writeJSON(resp http.ResponseWriter, v interface{}) {
+	resp.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(resp).Encode(v); err != nil {
+		log.Printf("Failed to encode response: %s", err)
+	}
+}
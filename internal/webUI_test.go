@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebUI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plotng-web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	svr := &Server{
+		config: &PlotConfig{ConfigPath: filepath.Join(dir, "config.json"), CurrentConfig: &Config{}},
+		active: map[int64]*ActivePlot{1: {PlotId: 1, State: PlotRunning, Phase: "1/4"}},
+	}
+	serve := func(method, url, token, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if len(token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp := httptest.NewRecorder()
+		svr.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := serve("GET", "/ui/", "", ""); resp.Code != 200 || !strings.Contains(resp.Body.String(), "<title>PlotNG</title>") {
+		t.Errorf("dashboard not served: %d", resp.Code)
+	}
+	resp := serve("GET", "/api/state", "", "")
+	var msg Msg
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil || len(msg.Actives) != 1 {
+		t.Errorf("unexpected state: %+v err=%v", msg, err)
+	}
+
+	// changes are refused until a token is configured
+	if resp := serve("DELETE", "/plots/1", "", ""); resp.Code != 403 {
+		t.Errorf("kill without AuthToken: expected 403, got %d", resp.Code)
+	}
+	if resp := serve("DELETE", "/", "", ""); resp.Code != 403 || !svr.active[1].running() {
+		t.Errorf("legacy kill without AuthToken: expected 403, got %d", resp.Code)
+	}
+	svr.config.CurrentConfig.AuthToken = "secret"
+	if resp := serve("GET", "/api/state", "", ""); resp.Code != 401 {
+		t.Errorf("state without token: expected 401, got %d", resp.Code)
+	}
+	if resp := serve("GET", "/ui/app.js", "", ""); resp.Code != 200 {
+		t.Errorf("assets should not need the token, got %d", resp.Code)
+	}
+	if resp := serve("DELETE", "/plots/1", "secret", ""); resp.Code != 200 {
+		t.Errorf("kill with token: expected 200, got %d", resp.Code)
+	}
+	if resp := serve("PUT", "/api/config", "secret", `{"Unknown": 1}`); resp.Code != 400 {
+		t.Errorf("invalid config: expected 400, got %d", resp.Code)
+	}
+	if resp := serve("PUT", "/api/config", "secret", `{"Hooks": {"OnStart": "touch /tmp/pwned"}, "AuthToken": "secret"}`); resp.Code != 403 {
+		t.Errorf("hooks changed over the API: expected 403, got %d", resp.Code)
+	}
+	if resp := serve("PUT", "/api/config", "secret", `{"ChiaRoot": "/tmp/evil", "AuthToken": "secret"}`); resp.Code != 403 {
+		t.Errorf("chia executable changed over the API: expected 403, got %d", resp.Code)
+	}
+	if resp := serve("PUT", "/api/config", "secret", `{"TempDirectory": ["/tmp1"], "AuthToken": "secret"}`); resp.Code != 204 {
+		t.Fatalf("config not saved: %d %s", resp.Code, resp.Body.String())
+	}
+	if !svr.config.ProcessConfig() || svr.config.CurrentConfig.TempDirectory[0] != "/tmp1" {
+		t.Errorf("saved config not loaded: %+v", svr.config.CurrentConfig)
+	}
+
+	// the token is never sent, and kept by a configuration without one
+	var config Config
+	if err := json.NewDecoder(serve("GET", "/api/config", "secret", "").Body).Decode(&config); err != nil || len(config.AuthToken) > 0 {
+		t.Errorf("unexpected config: %+v err=%v", config, err)
+	}
+	if resp := serve("PUT", "/api/config", "secret", `{"TempDirectory": ["/tmp2"]}`); resp.Code != 204 {
+		t.Fatalf("config not saved: %d %s", resp.Code, resp.Body.String())
+	}
+	svr.config.LastMod = time.Time{} // the file may be rewritten within the resolution of its mtime
+	if !svr.config.ProcessConfig() || svr.config.CurrentConfig.TempDirectory[0] != "/tmp2" || svr.config.CurrentConfig.AuthToken != "secret" {
+		t.Errorf("token lost: %+v", svr.config.CurrentConfig)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
@@ -19,29 +20,93 @@ type tableRow struct {
 	data SortableRow
 }
 
-// SortedTable is a wrapper around tview.Table which provides sortable column headers.  Rows are
-// identified by a key rather than by index.
+type sortKey struct {
+	column  int
+	reverse bool
+}
+
+// maxSortColumns is the number of columns the rows are sorted by, the last clicked first.
+const maxSortColumns = 3
+
+// SortedTable is a wrapper around tview.Table which provides sortable column headers and a filter
+// prompt (/).  Rows are identified by a key rather than by index.
 type SortedTable struct {
-	table       *tview.Table
-	values      []tableRow
-	curRow      int
-	curKey      string
-	sortColumn  int
-	sortReverse bool
+	table    *tview.Table
+	values   []tableRow
+	rows     []tableRow // values matching the filter, as shown
+	curRow   int
+	curKey   string
+	sortKeys []sortKey
+	headers  []string
+	columns  []int // fields shown, all if nil
+	title    string
+
+	filter      string
+	filterInput *tview.InputField
+	filtering   bool
 
 	columnAlign map[int]int
 
+	inputCapture         func(event *tcell.EventKey) *tcell.EventKey
 	selectionChangedFunc func(key string)
 }
 
 func (st *SortedTable) SetInputCapture(capture func(event *tcell.EventKey) *tcell.EventKey) {
-	st.table.SetInputCapture(capture)
+	st.inputCapture = capture
+}
+
+// handleKey edits the filter while the prompt is shown, and otherwise opens it on / before passing the
+// key to the input capture.
+func (st *SortedTable) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	if st.filtering {
+		switch event.Key() {
+		case tcell.KeyEnter, tcell.KeyTab:
+			st.stopFiltering()
+		case tcell.KeyEscape:
+			st.filterInput.SetText("")
+			st.stopFiltering()
+		default:
+			st.filterInput.InputHandler()(event, func(p tview.Primitive) {})
+		}
+		return nil
+	}
+	if event.Rune() == '/' {
+		st.filtering = true
+		st.filterInput.SetText(st.filter)
+		st.filterInput.Focus(func(p tview.Primitive) {})
+		return nil
+	}
+	if st.inputCapture != nil {
+		return st.inputCapture(event)
+	}
+	return event
+}
+
+func (st *SortedTable) stopFiltering() {
+	st.filtering = false
+	st.filterInput.Blur()
+}
+
+// SetFilter shows only the rows matching the filter: space separated terms which are either a substring
+// of any column or column:value, a substring of the columns whose header contains column.
+func (st *SortedTable) SetFilter(filter string) *SortedTable {
+	st.filter = strings.TrimSpace(filter)
+	return st
+}
+
+func (st *SortedTable) GetFilter() string {
+	return st.filter
 }
 
 // Mostly implement tview.Primitive with proxies
 func (st *SortedTable) Draw(screen tcell.Screen) {
 	st.Redraw()
 	st.table.Draw(screen)
+	if st.filtering {
+		x, y, width, height := st.table.GetInnerRect()
+		st.filterInput.SetRect(x, y+height-1, width, 1)
+		st.filterInput.Draw(screen)
+	}
 }
 
 func (st *SortedTable) GetRect() (int, int, int, int) {
@@ -91,11 +156,18 @@ func (st *SortedTable) MouseHandler() func(action tview.MouseAction, event *tcel
 func NewSortedTable() *SortedTable {
 	st := &SortedTable{
 		table:       tview.NewTable(),
+		filterInput: tview.NewInputField(),
+		sortKeys:    []sortKey{{column: 0}},
 		columnAlign: make(map[int]int),
 	}
 	st.table.SetFixed(1, 0)
 	st.table.InsertRow(0)
 	st.table.SetSelectionChangedFunc(st.selectionChanged)
+	st.table.SetInputCapture(st.handleKey)
+	st.filterInput.SetLabel("Filter: ")
+	st.filterInput.SetChangedFunc(func(text string) {
+		st.SetFilter(text)
+	})
 	return st
 }
 
@@ -114,8 +186,9 @@ func (st *SortedTable) SetTitleAlign(align int) *SortedTable {
 	return st
 }
 
+// SetTitle sets the title of the table, which shows the filter when there is one.
 func (st *SortedTable) SetTitle(title string) *SortedTable {
-	st.table.SetTitle(title)
+	st.title = title
 	return st
 }
 
@@ -130,9 +203,12 @@ func (st *SortedTable) selectionChanged(row, column int) {
 			st.table.Select(st.curRow, 0)
 		}
 	} else {
+		if row > len(st.rows) {
+			return
+		}
 		st.curRow = row
-		if st.curKey != st.values[row-1].key {
-			st.curKey = st.values[row-1].key
+		if st.curKey != st.rows[row-1].key {
+			st.curKey = st.rows[row-1].key
 			if st.selectionChangedFunc != nil {
 				st.selectionChangedFunc(st.curKey)
 			}
@@ -172,17 +248,66 @@ func (st *SortedTable) SetupFromType(value interface{}) *SortedTable {
 }
 
 func (st *SortedTable) setHeaders(headers ...string) *SortedTable {
-	for colIndex := len(headers); colIndex < st.table.GetColumnCount(); colIndex++ {
+	st.headers = headers
+	st.setHeaderCells()
+	return st
+}
+
+// setHeaderCells creates the header cells of the visible columns.
+func (st *SortedTable) setHeaderCells() {
+	for colIndex := len(st.visibleColumns()); colIndex < st.table.GetColumnCount(); colIndex++ {
 		cell := st.table.GetCell(0, colIndex)
 		cell.Text = ""
 		cell.Clicked = nil
 	}
-	for c, h := range headers {
-		cell := tview.NewTableCell(h)
+	for c, col := range st.visibleColumns() {
+		cell := tview.NewTableCell(st.headers[col])
 		cell.NotSelectable = true
-		cell.Clicked = st.setSortColumn(c)
+		cell.Clicked = st.setSortColumn(col)
 		st.table.SetCell(0, c, cell)
 	}
+}
+
+// Headers returns the headers of every column, visible or not.
+func (st *SortedTable) Headers() []string {
+	return st.headers
+}
+
+func (st *SortedTable) visibleColumns() []int {
+	if st.columns != nil {
+		return st.columns
+	}
+	columns := make([]int, len(st.headers))
+	for i := range columns {
+		columns[i] = i
+	}
+	return columns
+}
+
+// VisibleColumns returns the headers of the columns shown.
+func (st *SortedTable) VisibleColumns() []string {
+	var headers []string
+	for _, col := range st.visibleColumns() {
+		headers = append(headers, st.headers[col])
+	}
+	return headers
+}
+
+// SetVisibleColumns shows only the columns with the given headers, in the order of the table, or every
+// column if none of the headers are known.
+func (st *SortedTable) SetVisibleColumns(headers []string) *SortedTable {
+	st.columns = nil
+	for col, header := range st.headers {
+		for _, h := range headers {
+			if strings.EqualFold(h, header) {
+				st.columns = append(st.columns, col)
+				break
+			}
+		}
+	}
+	// the rows are set again by the next Redraw
+	st.table.Clear()
+	st.setHeaderCells()
 	return st
 }
 
@@ -228,41 +353,71 @@ func (st *SortedTable) ClearRowData(key string) *SortedTable {
 	return st
 }
 
+// setSortColumn sorts by col first, in reverse if it already was, then by the columns sorted by before.
 func (st *SortedTable) setSortColumn(col int) func() bool {
 	return func() bool {
-		if st.sortColumn == col {
-			st.sortReverse = !st.sortReverse
-		} else {
-			st.sortColumn = col
-			st.sortReverse = false
+		keys := []sortKey{{column: col}}
+		for i, key := range st.sortKeys {
+			if key.column == col {
+				if i == 0 {
+					keys[0].reverse = !key.reverse
+				}
+			} else if len(keys) < maxSortColumns {
+				keys = append(keys, key)
+			}
 		}
+		st.sortKeys = keys
 		return true
 	}
 }
 
+// SortBy sorts the rows by the given columns, the first one first.
+func (st *SortedTable) SortBy(columns ...int) *SortedTable {
+	st.sortKeys = nil
+	for _, col := range columns {
+		st.sortKeys = append(st.sortKeys, sortKey{column: col})
+	}
+	return st
+}
+
 func (st *SortedTable) redrawHeaders() {
-	for c := 0; c < st.table.GetColumnCount(); c++ {
-		if c == st.sortColumn {
-			if !st.sortReverse {
-				st.table.GetCell(0, c).SetTextColor(tcell.ColorGreen)
+	for c, col := range st.visibleColumns() {
+		cell := st.table.GetCell(0, c)
+		cell.SetTextColor(tcell.ColorYellow)
+		cell.Text = st.headers[col]
+		for i, key := range st.sortKeys {
+			if key.column != col {
+				continue
+			}
+			if !key.reverse {
+				cell.SetTextColor(tcell.ColorGreen)
 			} else {
-				st.table.GetCell(0, c).SetTextColor(tcell.ColorRed)
+				cell.SetTextColor(tcell.ColorRed)
+			}
+			if len(st.sortKeys) > 1 {
+				cell.Text += fmt.Sprintf(" %d", i+1)
 			}
-		} else {
-			st.table.GetCell(0, c).SetTextColor(tcell.ColorYellow)
 		}
 	}
 }
 
+func (st *SortedTable) redrawTitle() {
+	title := st.title
+	if len(st.filter) > 0 {
+		title += fmt.Sprintf("[filter: %s, %d of %d] ", st.filter, len(st.rows), len(st.values))
+	}
+	st.table.SetTitle(title)
+}
+
 func (st *SortedTable) GetSelection() string {
-	if st.curRow > 0 && st.curRow <= len(st.values) {
-		return st.values[st.curRow-1].key
+	if st.curRow > 0 && st.curRow <= len(st.rows) {
+		return st.rows[st.curRow-1].key
 	}
 	return ""
 }
 
 func (st *SortedTable) Select(key string) *SortedTable {
-	for row, value := range st.values {
+	for row, value := range st.rows {
 		if value.key == key {
 			st.table.Select(row+1, 0)
 			break
@@ -279,57 +434,113 @@ func (st *SortedTable) sortData() {
 			return true
 		} else if row1Value == nil {
 			return false
-		} else {
-			// TODO: We should validate the types are the same, and also
-			//       ensure it matches what was passed to SetupFromType.
-			v1 := reflect.ValueOf(row1Value).Elem()
-			v2 := reflect.ValueOf(row2Value).Elem()
-			if v1.NumField() != v2.NumField() || v1.NumField() < st.sortColumn {
+		}
+		// TODO: We should validate the types are the same, and also
+		//       ensure it matches what was passed to SetupFromType.
+		v1 := reflect.ValueOf(row1Value).Elem()
+		v2 := reflect.ValueOf(row2Value).Elem()
+		if v1.NumField() != v2.NumField() {
+			return false
+		}
+		for _, key := range st.sortKeys {
+			if v1.NumField() <= key.column {
 				return false
 			}
-			f1 := v1.Field(st.sortColumn)
-			f2 := v2.Field(st.sortColumn)
-			switch f1.Kind() {
-			case reflect.String:
-				return f1.String() < f2.String() != st.sortReverse
-			case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
-				return f1.Int() < f2.Int() != st.sortReverse
-			case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
-				return f1.Uint() < f2.Uint() != st.sortReverse
-			}
-			switch c1 := f1.Interface().(type) {
-			case time.Time:
-				return c1.Before(f2.Interface().(time.Time)) != st.sortReverse
-			default:
-				panic(fmt.Sprintf("unexpected type, kind=%d", v1.Field(st.sortColumn).Kind()))
+			if c := compareFields(v1.Field(key.column), v2.Field(key.column)); c != 0 {
+				return c < 0 != key.reverse
 			}
 		}
+		return false
 	})
 }
 
+// compareFields returns -1, 0 or 1 when f1 is less, equal or greater than f2.
+func compareFields(f1, f2 reflect.Value) int {
+	less, greater := false, false
+	switch f1.Kind() {
+	case reflect.String:
+		less, greater = f1.String() < f2.String(), f1.String() > f2.String()
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		less, greater = f1.Int() < f2.Int(), f1.Int() > f2.Int()
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		less, greater = f1.Uint() < f2.Uint(), f1.Uint() > f2.Uint()
+	default:
+		switch c1 := f1.Interface().(type) {
+		case time.Time:
+			c2 := f2.Interface().(time.Time)
+			less, greater = c1.Before(c2), c1.After(c2)
+		default:
+			panic(fmt.Sprintf("unexpected type, kind=%d", f1.Kind()))
+		}
+	}
+	if less {
+		return -1
+	} else if greater {
+		return 1
+	}
+	return 0
+}
+
+// filterData selects the rows matching the filter.
+func (st *SortedTable) filterData() {
+	st.rows = st.rows[:0]
+	terms := strings.Fields(strings.ToLower(st.filter))
+	for _, row := range st.values {
+		if row.data != nil && matchFilter(terms, st.headers, row.data.Strings()) {
+			st.rows = append(st.rows, row)
+		}
+	}
+}
+
+// matchFilter returns true if every term is a substring of any of the values, or for column:value terms,
+// of the values of a column whose header contains column.  The terms are lower case.
+func matchFilter(terms []string, headers []string, values []string) bool {
+	for _, term := range terms {
+		column, value := "", term
+		if i := strings.Index(term, ":"); i > 0 {
+			column, value = term[:i], term[i+1:]
+		}
+		matched := false
+		for c, v := range values {
+			if len(column) > 0 {
+				if c >= len(headers) || !strings.Contains(strings.ToLower(strings.ReplaceAll(headers[c], " ", "")), column) {
+					continue
+				}
+			}
+			if strings.Contains(strings.ToLower(v), value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
 func (st *SortedTable) SetColumnAlign(col int, align int) *SortedTable {
 	st.columnAlign[col] = align
 	return st
 }
 
 func (st *SortedTable) updateData() {
-	for rowIndex, rowData := range st.values {
+	columns := st.visibleColumns()
+	for rowIndex, rowData := range st.rows {
 		strData := rowData.data.Strings()
-		colIndex := 0
-		for ; colIndex < len(strData); colIndex++ {
-			cellText := strData[colIndex]
+		for colIndex, col := range columns {
+			cellText := ""
+			if col < len(strData) {
+				cellText = strData[col]
+			}
 			cell := tview.NewTableCell(cellText)
-			if align, ok := st.columnAlign[colIndex]; ok {
+			if align, ok := st.columnAlign[col]; ok {
 				cell.Align = align
 			}
 			st.table.SetCell(rowIndex+1, colIndex, cell)
 		}
-		for ; colIndex < st.table.GetColumnCount(); colIndex++ {
-			cell := tview.NewTableCell("")
-			st.table.SetCell(rowIndex+1, colIndex, cell)
-		}
 	}
-	for st.table.GetRowCount() > len(st.values)+1 {
+	for st.table.GetRowCount() > len(st.rows)+1 {
 		st.table.RemoveRow(st.table.GetRowCount() - 1)
 	}
 }
@@ -338,6 +549,8 @@ func (st *SortedTable) Redraw() {
 	st.redrawHeaders()
 	selectedKey := st.GetSelection()
 	st.sortData()
+	st.filterData()
+	st.redrawTitle()
 	st.updateData()
 	st.Select(selectedKey)
 }
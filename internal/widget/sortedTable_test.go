@@ -0,0 +1,102 @@
+package widget
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRow struct {
+	Host   string    `header:"Host"`
+	Status string    `header:"Status"`
+	Dir    string    `header:"Plot Dir"`
+	Count  int       `header:"Count"`
+	Start  time.Time `header:"Start"`
+}
+
+func (row *testRow) Strings() []string {
+	return []string{row.Host, row.Status, row.Dir, "", ""}
+}
+
+func TestMatchFilter(t *testing.T) {
+	headers := []string{"Host", "Status", "Plot Dir"}
+	values := []string{"plotter1", "Running", "/mnt/ssd1"}
+	for filter, expected := range map[string]bool{
+		"":                       true,
+		"plotter1":               true,
+		"run ssd1":               true,
+		"run ssd2":               false,
+		"host:plotter":           true,
+		"host:ssd1":              false,
+		"status:running":         true,
+		"dir:/mnt/ssd":           true,
+		"plotdir:ssd1 host:plot": true,
+		"size:1":                 false,
+	} {
+		if matched := matchFilter(strings.Fields(filter), headers, values); matched != expected {
+			t.Errorf("%q: expected %v, got %v", filter, expected, matched)
+		}
+	}
+}
+
+func TestSortAndFilter(t *testing.T) {
+	st := NewSortedTable().SetupFromType(testRow{})
+	st.SetRowData("a", &testRow{Host: "h2", Status: "Running", Dir: "/tmp1", Count: 1})
+	st.SetRowData("b", &testRow{Host: "h1", Status: "Running", Dir: "/tmp2", Count: 2})
+	st.SetRowData("c", &testRow{Host: "h1", Status: "Finished", Dir: "/tmp1", Count: 1})
+	st.SetRowData("d", &testRow{Host: "h2", Status: "Finished", Dir: "/tmp2", Count: 2})
+	keys := func() string {
+		var keys []string
+		for _, row := range st.rows {
+			keys = append(keys, row.key)
+		}
+		return strings.Join(keys, "")
+	}
+
+	// by count, then host
+	st.setSortColumn(3)()
+	st.Redraw()
+	if k := keys(); k != "cabd" {
+		t.Errorf("sorted by count and host: got %s", k)
+	}
+	// count reversed, then host
+	st.setSortColumn(3)()
+	st.Redraw()
+	if k := keys(); k != "bdca" {
+		t.Errorf("sorted by reversed count and host: got %s", k)
+	}
+	if header := st.table.GetCell(0, 3).Text; header != "Count 1" {
+		t.Errorf("unexpected header %q", header)
+	}
+
+	st.SetTitle(" Plots ")
+	st.SetFilter("host:h1 run")
+	st.Redraw()
+	if k := keys(); k != "b" {
+		t.Errorf("filtered: got %s", k)
+	}
+	if title := st.table.GetTitle(); title != " Plots [filter: host:h1 run, 1 of 4] " {
+		t.Errorf("unexpected title %q", title)
+	}
+	if len(st.Keys()) != 4 {
+		t.Errorf("Keys should return the filtered out rows too: %v", st.Keys())
+	}
+}
+
+func TestVisibleColumns(t *testing.T) {
+	st := NewSortedTable().SetupFromType(testRow{})
+	st.SetRowData("a", &testRow{Host: "h1", Status: "Running", Dir: "/tmp1"})
+	st.SetVisibleColumns([]string{"plot dir", "Host"})
+	st.Redraw()
+	if columns := st.VisibleColumns(); len(columns) != 2 || columns[0] != "Host" || columns[1] != "Plot Dir" {
+		t.Errorf("unexpected visible columns %v", columns)
+	}
+	if st.table.GetColumnCount() != 2 || st.table.GetCell(0, 1).Text != "Plot Dir" || st.table.GetCell(1, 1).Text != "/tmp1" {
+		t.Errorf("unexpected table: %d columns, %q %q", st.table.GetColumnCount(), st.table.GetCell(0, 1).Text, st.table.GetCell(1, 1).Text)
+	}
+	st.SetVisibleColumns(nil)
+	st.Redraw()
+	if st.table.GetColumnCount() != 5 || st.table.GetCell(1, 1).Text != "Running" {
+		t.Errorf("expected every column, got %d", st.table.GetColumnCount())
+	}
+}